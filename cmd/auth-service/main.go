@@ -17,13 +17,26 @@ func main() {
 	application := app.NewApp(
 		log,
 		cfg.GRPC.Port,
+		cfg.HTTP,
+		cfg.TrustedProxies,
+		cfg.JWT,
+		cfg.Mailer,
+		cfg.EmailVerification,
+		cfg.PasswordReset,
+		cfg.LoginCode,
+		cfg.OAuth,
+		cfg.PasswordPolicy,
+		cfg.PasswordHash,
+		cfg.Mfa,
+		cfg.Webauthn,
 		cfg.PostgresURL,
 		cfg.AccessTokenTTL,
 		cfg.RefreshTokenTTL,
 		cfg.SchemaRegistryUrl,
 		cfg.KafkaHost,
 	)
-	application.GrpcApp.MustRun()
+	go application.GrpcApp.MustRun()
+	go application.HttpApp.MustRun()
 
 	stopWait(application)
 }
@@ -33,4 +46,5 @@ func stopWait(application *app.App) {
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 	<-stop
 	application.GrpcApp.Stop()
+	application.HttpApp.Stop()
 }
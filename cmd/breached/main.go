@@ -0,0 +1,211 @@
+package main
+
+import (
+	"auth-service/internal/lib/password"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const usage = `Usage: breached [flags] <command>
+
+Commands:
+  build  build bloom filter from HIBP dataset, either a dir of range files
+         (<prefix>.txt with "<suffix>:<count>" lines) or one "<hash>:<count>" file
+  check  read passwords from stdin, one per line, and tell which are breached
+         according to bloom filter or dir of range files
+
+Flags:
+`
+
+var rangeFileName = regexp.MustCompile(`^[0-9A-Fa-f]{5}\.txt$`)
+
+func main() {
+	var src, out string
+	var falsePositiveRate float64
+	var minCount int
+	var expected uint64
+
+	flag.StringVar(&src, "src", "", "HIBP dataset to build from, bloom filter or range files dir to check with")
+	flag.StringVar(&out, "out", "breached.bloom", "bloom filter file to write")
+	flag.Float64Var(&falsePositiveRate, "fp", 0.001, "share of not breached passwords the filter reports as breached")
+	flag.IntVar(&minCount, "min-count", 1, "skip hashes seen fewer times in breaches")
+	flag.Uint64Var(&expected, "n", 0, "expected number of hashes, dataset is counted in an extra pass if 0")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if src == "" {
+		log.Fatal("Source is required. Use the --src flag to provide it.")
+	}
+	// padded range responses contain fake hashes with zero count
+	minCount = max(minCount, 1)
+
+	switch flag.Arg(0) {
+	case "build":
+		build(src, out, falsePositiveRate, minCount, expected)
+	case "check":
+		check(src, minCount)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func build(src string, out string, falsePositiveRate float64, minCount int, expected uint64) {
+	if expected == 0 {
+		err := forEachHash(src, minCount, func(string) error {
+			expected++
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("Can not read dataset: %v", err)
+		}
+		log.Printf("Dataset has %d hashes seen at least %d times", expected, minCount)
+	}
+
+	filter, err := password.NewBloomFilter(expected, falsePositiveRate)
+	if err != nil {
+		log.Fatalf("Can not create filter: %v", err)
+	}
+	bits, hashes := filter.Size()
+	log.Printf("Filter takes %d MiB, %d hash functions", bits/8/1024/1024, hashes)
+
+	if err := forEachHash(src, minCount, filter.AddSHA1Hex); err != nil {
+		log.Fatalf("Can not read dataset: %v", err)
+	}
+
+	// filter is renamed into place, so running service never opens half written file
+	tmp, err := os.CreateTemp(filepath.Dir(out), ".breached-*")
+	if err != nil {
+		log.Fatalf("Can not create filter file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		log.Fatalf("Can not create filter file: %v", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	if _, err := filter.WriteTo(writer); err != nil {
+		log.Fatalf("Can not write filter: %v", err)
+	}
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("Can not write filter: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		log.Fatalf("Can not write filter: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), out); err != nil {
+		log.Fatalf("Can not write filter: %v", err)
+	}
+
+	log.Printf("Filter written to %s", out)
+}
+
+func check(src string, minCount int) {
+	var checker password.BreachChecker
+
+	info, err := os.Stat(src)
+	if err != nil {
+		log.Fatalf("Can not open source: %v", err)
+	}
+	if info.IsDir() {
+		checker, err = password.NewRangeDir(src, minCount)
+	} else {
+		checker, err = password.OpenBloomFilter(src)
+	}
+	if err != nil {
+		log.Fatalf("Can not open source: %v", err)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		breached, err := checker.IsBreached(scanner.Text())
+		if err != nil {
+			log.Fatalf("Can not check password: %v", err)
+		}
+		if breached {
+			fmt.Println("breached")
+		} else {
+			fmt.Println("not found")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Can not read stdin: %v", err)
+	}
+}
+
+// forEachHash calls fn with full hex SHA-1 of every dataset entry seen at least minCount times
+func forEachHash(src string, minCount int, fn func(hash string) error) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return forEachLine(src, "", minCount, fn)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && rangeFileName.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no range files in %s", src)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prefix := strings.ToUpper(strings.TrimSuffix(name, ".txt"))
+		if err := forEachLine(filepath.Join(src, name), prefix, minCount, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func forEachLine(path string, prefix string, minCount int, fn func(hash string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		hash, count, err := password.ParseHashCount(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if count < minCount {
+			continue
+		}
+		if err := fn(prefix + hash); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return scanner.Err()
+}
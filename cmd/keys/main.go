@@ -0,0 +1,115 @@
+package main
+
+import (
+	"auth-service/internal/lib/jwt"
+	keyservice "auth-service/internal/services/keys"
+	"auth-service/internal/storage/keydir"
+	"auth-service/internal/storage/postgres"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const usage = `Usage: keys [flags] <command> [args]
+
+Commands:
+  list                  show keys which are still used for signing or verification
+  generate [algorithm]  create pending key (RS256 by default, ES256 and EdDSA are supported)
+  promote <kid>         start signing with pending key, current active key is retired
+  retire <kid>          stop accepting tokens signed with key after grace period
+
+Flags:
+`
+
+func main() {
+	var source, dir, dsn string
+	var grace time.Duration
+
+	flag.StringVar(&source, "source", "dir", "keys storage: dir or postgres")
+	flag.StringVar(&dir, "dir", "./keys", "keys directory for dir source")
+	flag.StringVar(&dsn, "dsn", "", "PostgreSQL DSN for postgres source")
+	flag.DurationVar(&grace, "grace", 30*24*time.Hour, "how long tokens signed with retired key stay valid")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var storage keyservice.Storage
+	switch source {
+	case "dir":
+		dirStorage, err := keydir.NewStorage(dir)
+		if err != nil {
+			log.Fatalf("Can not open keys dir: %v", err)
+		}
+		storage = dirStorage
+	case "postgres":
+		if dsn == "" {
+			log.Fatal("DSN is required for postgres source. Use the --dsn flag to provide it.")
+		}
+		pgStorage, err := postgres.NewStorage(dsn)
+		if err != nil {
+			log.Fatalf("Can not connect to db: %v", err)
+		}
+		storage = pgStorage
+	default:
+		log.Fatalf("Unknown source %q", source)
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	keyService := keyservice.NewKeyService(logger, storage, grace)
+	ctx := context.Background()
+
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "list":
+		keys, err := keyService.List(ctx)
+		if err != nil {
+			log.Fatalf("Can not list keys: %v", err)
+		}
+		for _, key := range keys {
+			verifyUntil := "-"
+			if key.VerifyUntil != nil {
+				verifyUntil = key.VerifyUntil.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\n", key.ID, key.Algorithm, key.Status, verifyUntil)
+		}
+	case "generate":
+		algorithm := jwt.AlgRS256
+		if len(args) > 0 {
+			algorithm = args[0]
+		}
+		key, err := keyService.Generate(ctx, algorithm)
+		if err != nil {
+			log.Fatalf("Can not generate key: %v", err)
+		}
+		fmt.Println(key.ID)
+	case "promote":
+		if len(args) != 1 {
+			log.Fatal("kid is required")
+		}
+		if err := keyService.Promote(ctx, args[0]); err != nil {
+			log.Fatalf("Can not promote key: %v", err)
+		}
+		log.Printf("Key '%s' is active now", args[0])
+	case "retire":
+		if len(args) != 1 {
+			log.Fatal("kid is required")
+		}
+		if err := keyService.Retire(ctx, args[0]); err != nil {
+			log.Fatalf("Can not retire key: %v", err)
+		}
+		log.Printf("Key '%s' retired", args[0])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/secret"
+	"auth-service/internal/storage/postgres"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const usage = `Usage: oauth-clients [flags] <command> [args]
+
+Commands:
+  create         register client, its secret is printed once
+  list           show registered clients
+  delete <id>    remove client together with its codes and consents
+
+Flags:
+`
+
+// listFlag collects values of repeated flag
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	var dsn, name string
+	var redirectURIs, scopes listFlag
+	var public, firstParty bool
+
+	flag.StringVar(&dsn, "dsn", "", "PostgreSQL DSN")
+	flag.StringVar(&name, "name", "", "client name shown on consent page")
+	flag.Var(&redirectURIs, "redirect-uri", "allowed redirect URI, repeat for several")
+	flag.Var(&scopes, "scope", "scope client may request, repeat for several")
+	flag.BoolVar(&public, "public", false, "client can not keep secret (SPA, mobile app)")
+	flag.BoolVar(&firstParty, "first-party", false, "trusted client, users are not asked for consent")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if dsn == "" {
+		log.Fatal("DSN is required. Use the --dsn flag to provide it.")
+	}
+	storage, err := postgres.NewStorage(dsn)
+	if err != nil {
+		log.Fatalf("Can not connect to db: %v", err)
+	}
+	ctx := context.Background()
+
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "create":
+		if name == "" {
+			log.Fatal("name is required")
+		}
+		if len(redirectURIs) == 0 {
+			log.Fatal("at least one redirect-uri is required")
+		}
+		for _, redirectURI := range redirectURIs {
+			if err := validateRedirectURI(redirectURI); err != nil {
+				log.Fatalf("Invalid redirect uri '%s': %v", redirectURI, err)
+			}
+		}
+		if len(scopes) == 0 {
+			log.Fatal("at least one scope is required")
+		}
+
+		client := models.OAuthClient{
+			ID:           secret.Generate(16),
+			Name:         name,
+			RedirectURIs: redirectURIs,
+			Scopes:       scopes,
+			FirstParty:   firstParty,
+		}
+		var clientSecret string
+		if !public {
+			clientSecret = secret.Generate(32)
+			client.SecretHash = secret.Hash(clientSecret)
+		}
+
+		if err := storage.CreateOAuthClient(ctx, client); err != nil {
+			log.Fatalf("Can not create client: %v", err)
+		}
+		fmt.Println("client_id:", client.ID)
+		if clientSecret != "" {
+			fmt.Println("client_secret:", clientSecret)
+		}
+	case "list":
+		clients, err := storage.ListOAuthClients(ctx)
+		if err != nil {
+			log.Fatalf("Can not list clients: %v", err)
+		}
+		for _, client := range clients {
+			kind := "confidential"
+			if client.Public() {
+				kind = "public"
+			}
+			if client.FirstParty {
+				kind += ",first-party"
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n",
+				client.ID,
+				client.Name,
+				kind,
+				strings.Join(client.Scopes, " "),
+				strings.Join(client.RedirectURIs, " "),
+			)
+		}
+	case "delete":
+		if len(args) != 1 {
+			log.Fatal("client id is required")
+		}
+		if err := storage.DeleteOAuthClient(ctx, args[0]); err != nil {
+			log.Fatalf("Can not delete client: %v", err)
+		}
+		log.Printf("Client '%s' deleted", args[0])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// validateRedirectURI checks RFC 6749 section 3.1.2 requirements, redirect URI is compared exactly
+func validateRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return err
+	}
+	if !u.IsAbs() {
+		return errors.New("must be absolute")
+	}
+	if strings.Contains(redirectURI, "#") {
+		return errors.New("must not contain fragment")
+	}
+	// custom schemes of mobile apps have no host
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return errors.New("host is required")
+	}
+	if u.Scheme == "http" && u.Hostname() != "localhost" && u.Hostname() != "127.0.0.1" {
+		return errors.New("http is allowed for localhost only")
+	}
+
+	return nil
+}
@@ -9,6 +9,16 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// permissions granted to roles directly, admin also inherits everything customer has
+var rolePermissions = map[string][]string{
+	"customer": {"projects:read", "projects:write"},
+	"admin":    {"users:read", "users:delete", "sessions:revoke", "roles:manage"},
+}
+
+var roleParents = map[string]string{
+	"admin": "customer",
+}
+
 func main() {
 	dsn := flag.String("dsn", "", "Database connection string (DSN)")
 	flag.Parse()
@@ -24,6 +34,8 @@ func main() {
 	defer db.Close()
 
 	insertRoles(db)
+	insertRoleParents(db)
+	insertPermissions(db)
 }
 
 func insertRoles(db *sql.DB) {
@@ -43,3 +55,47 @@ func insertRoles(db *sql.DB) {
 		}
 	}
 }
+
+func insertRoleParents(db *sql.DB) {
+	for role, parent := range roleParents {
+		query := `UPDATE role
+				SET parent_id = (SELECT id FROM role WHERE name = $2)
+				WHERE name = $1`
+		_, err := db.Exec(query, role, parent)
+
+		if err != nil {
+			log.Printf("Err while seed parent of role '%s': %v", role, err)
+			panic(fmt.Sprintf("Err while seed parent of role '%s': %v", role, err))
+		} else {
+			log.Printf("Role '%s' successfully inherits '%s'", role, parent)
+		}
+	}
+}
+
+func insertPermissions(db *sql.DB) {
+	for role, permissions := range rolePermissions {
+		for _, permission := range permissions {
+			query := `INSERT INTO permission (name)
+					VALUES ($1)
+					ON CONFLICT (name) DO NOTHING`
+			_, err := db.Exec(query, permission)
+			if err != nil {
+				log.Printf("Err while seed permission '%s': %v", permission, err)
+				panic(fmt.Sprintf("Err while seed permission '%s': %v", permission, err))
+			}
+
+			query = `INSERT INTO role_permission (role_id, permission_id)
+					SELECT r.id, p.id FROM role r, permission p
+					WHERE r.name = $1 AND p.name = $2
+					ON CONFLICT DO NOTHING`
+			_, err = db.Exec(query, role, permission)
+
+			if err != nil {
+				log.Printf("Err while seed permission '%s' of role '%s': %v", permission, role, err)
+				panic(fmt.Sprintf("Err while seed permission '%s' of role '%s': %v", permission, role, err))
+			} else {
+				log.Printf("Permission '%s' successfully granted to '%s'", permission, role)
+			}
+		}
+	}
+}
@@ -2,21 +2,50 @@ package app
 
 import (
 	grpcapp "auth-service/internal/app/grpc"
+	httpapp "auth-service/internal/app/http"
+	"auth-service/internal/config"
+	authhttp "auth-service/internal/http/auth"
 	"auth-service/internal/kafka"
+	"auth-service/internal/lib/clientip"
+	"auth-service/internal/lib/jwt"
+	"auth-service/internal/lib/password"
+	"auth-service/internal/lib/secret"
+	"auth-service/internal/lib/webauthn"
+	"auth-service/internal/mailer"
+	accessservice "auth-service/internal/services/access"
 	authservice "auth-service/internal/services/auth"
+	keyservice "auth-service/internal/services/keys"
 	userservice "auth-service/internal/services/user"
+	verificationservice "auth-service/internal/services/verification"
+	"auth-service/internal/storage/keydir"
 	"auth-service/internal/storage/postgres"
+	"context"
+	"encoding/base64"
+	"fmt"
 	"log/slog"
 	"time"
 )
 
 type App struct {
 	GrpcApp *grpcapp.GrpcApp
+	HttpApp *httpapp.HttpApp
 }
 
 func NewApp(
 	log *slog.Logger,
 	grpcPort int,
+	httpConfig config.HTTPConfig,
+	trustedProxies []string,
+	jwtConfig config.JWTConfig,
+	mailerConfig config.MailerConfig,
+	verificationConfig config.EmailVerificationConfig,
+	passwordResetConfig config.PasswordResetConfig,
+	loginCodeConfig config.LoginCodeConfig,
+	oauthConfig config.OAuthConfig,
+	passwordPolicyConfig config.PasswordPolicyConfig,
+	passwordHashConfig config.PasswordHashConfig,
+	mfaConfig config.MfaConfig,
+	webauthnConfig config.WebauthnConfig,
 	postgresURL string,
 	accessTokenTTL time.Duration,
 	refreshTokenTTL time.Duration,
@@ -28,20 +57,273 @@ func NewApp(
 		panic(err)
 	}
 
+	mustSetupKeys(log, jwtConfig, storage)
+
+	proxies, err := clientip.ParseProxies(trustedProxies)
+	if err != nil {
+		panic(err)
+	}
+
 	schemaManager := kafka.NewSchemaManager(schemaRegistryUrl)
 	kafkaProducer := kafka.NewKafkaProducer(kafkaHost, log, schemaManager)
 
-	authService := authservice.NewAuthService(log, storage, accessTokenTTL, refreshTokenTTL, kafkaProducer)
-	userService := userservice.NewUserService(log, storage)
+	mailSender := mustSetupMailer(log, mailerConfig)
+
+	// stays nil interface without key, TOTP is disabled then while passkeys still work
+	var secretCipher authservice.SecretCipher
+	if mfaConfig.SecretKey != "" {
+		mfaKey, err := base64.StdEncoding.DecodeString(mfaConfig.SecretKey)
+		if err != nil {
+			panic(fmt.Sprintf("invalid mfa secret key: %v", err))
+		}
+		cipher, err := secret.NewCipher(mfaKey)
+		if err != nil {
+			panic(err)
+		}
+		secretCipher = cipher
+	} else {
+		log.Warn("MFA_SECRET_KEY is not set, TOTP second factor is disabled")
+	}
+
+	relyingParty, err := webauthn.NewRelyingParty(
+		webauthnConfig.RPID,
+		webauthnConfig.RPName,
+		webauthnConfig.Origins,
+		webauthnConfig.Timeout,
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	verificationService := verificationservice.NewVerificationService(
+		log,
+		storage,
+		mailSender,
+		verificationConfig.TokenTTL,
+		verificationConfig.ResendInterval,
+		verificationConfig.URL,
+	)
+	authService := authservice.NewAuthService(
+		log,
+		storage,
+		accessTokenTTL,
+		refreshTokenTTL,
+		kafkaProducer,
+		verificationService,
+		mailSender,
+		authservice.PasswordResetOptions{
+			TokenTTL: passwordResetConfig.TokenTTL,
+			URL:      passwordResetConfig.URL,
+		},
+		authservice.LoginCodeOptions{
+			TTL:            loginCodeConfig.TTL,
+			MaxAttempts:    loginCodeConfig.MaxAttempts,
+			ResendInterval: loginCodeConfig.ResendInterval,
+			URL:            loginCodeConfig.URL,
+			MaxFailures:    loginCodeConfig.MaxFailures,
+			FailureWindow:  loginCodeConfig.FailureWindow,
+		},
+		authservice.OAuthOptions{
+			CodeTTL: oauthConfig.CodeTTL,
+		},
+		mustSetupPasswordPolicy(passwordPolicyConfig),
+		mustSetupPasswordHasher(passwordHashConfig),
+		secretCipher,
+		authservice.MfaOptions{
+			Issuer:        mfaConfig.Issuer,
+			ChallengeTTL:  mfaConfig.ChallengeTTL,
+			MaxAttempts:   mfaConfig.MaxAttempts,
+			MaxFailures:   mfaConfig.MaxFailures,
+			FailureWindow: mfaConfig.FailureWindow,
+		},
+		relyingParty,
+		verificationConfig.Required,
+	)
+	userService := userservice.NewUserService(log, storage, authService)
+	accessService := accessservice.NewAccessService(log, storage)
 
 	grpcApp := grpcapp.NewGrpcApp(
 		log,
 		authService,
 		userService,
+		accessService,
+		verificationService,
+		proxies,
 		grpcPort,
 	)
 
+	httpApp := httpapp.NewHttpApp(
+		log,
+		authhttp.NewHandler(log, authService, userService, httpConfig.IntrospectionClients, proxies, oauthConfig.LoginURL),
+		httpConfig.Port,
+	)
+
 	return &App{
 		GrpcApp: grpcApp,
+		HttpApp: httpApp,
+	}
+}
+
+// mustSetupKeys configures jwt claims and installs key ring. Rotatable sources are reloaded in background
+func mustSetupKeys(log *slog.Logger, jwtConfig config.JWTConfig, storage *postgres.Storage) {
+	jwt.Configure(jwt.Options{
+		Issuer:          jwtConfig.Issuer,
+		DefaultAudience: jwtConfig.DefaultAudience,
+		OAuthAudience:   jwtConfig.OAuthAudience,
+		ClientAudiences: jwtConfig.ClientAudiences,
+		Leeway:          jwtConfig.ClockSkew,
+	})
+
+	var keyStorage keyservice.Storage
+
+	switch jwtConfig.KeysSource {
+	case "file":
+		signingKey, err := jwt.LoadSigningKey(jwtConfig.PrivateKeyPath, jwtConfig.Algorithm, jwtConfig.KeyID)
+		if err != nil {
+			panic(err)
+		}
+		keyRing, err := jwt.NewKeyRing([]*jwt.SigningKey{signingKey})
+		if err != nil {
+			panic(err)
+		}
+		jwt.SetKeyRing(keyRing)
+		return
+	case "dir":
+		dirStorage, err := keydir.NewStorage(jwtConfig.KeysDir)
+		if err != nil {
+			panic(err)
+		}
+		keyStorage = dirStorage
+	case "postgres":
+		keyStorage = storage
+	default:
+		panic(fmt.Sprintf("unknown jwt keys source %q", jwtConfig.KeysSource))
+	}
+
+	keyService := keyservice.NewKeyService(log, keyStorage, jwtConfig.KeysGracePeriod)
+	if err := keyService.LoadKeyRing(context.Background()); err != nil {
+		panic(err)
+	}
+	go keyService.RunReload(context.Background(), jwtConfig.KeysReloadInterval)
+}
+
+// mustSetupMailer creates mailer of configured backend rendering embedded or overridden templates
+func mustSetupMailer(log *slog.Logger, mailerConfig config.MailerConfig) *mailer.TemplateMailer {
+	templates, err := mailer.LoadTemplates(mailerConfig.TemplatesDir, mailerConfig.DefaultLocale)
+	if err != nil {
+		panic(err)
+	}
+
+	var sender mailer.Mailer
+
+	switch mailerConfig.Backend {
+	case "smtp":
+		sender, err = mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     mailerConfig.SMTPHost,
+			Port:     mailerConfig.SMTPPort,
+			Username: mailerConfig.SMTPUsername,
+			Password: mailerConfig.SMTPPassword,
+			TLS:      mailerConfig.SMTPTLS,
+			From:     mailerConfig.From,
+			Timeout:  mailerConfig.SMTPTimeout,
+		})
+	case "file":
+		sender, err = mailer.NewFileMailer(mailerConfig.Dir, mailerConfig.From)
+	case "memory":
+		sender = mailer.NewMemoryMailer()
+	case "log":
+		sender = mailer.NewLogMailer(log)
+	default:
+		panic(fmt.Sprintf("unknown mailer backend %q", mailerConfig.Backend))
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	return mailer.NewTemplateMailer(sender, templates)
+}
+
+// mustSetupPasswordPolicy creates policy checking configured rules and local breach dataset if any
+func mustSetupPasswordPolicy(policyConfig config.PasswordPolicyConfig) *password.Policy {
+	policy := &password.Policy{
+		MinLength:      policyConfig.MinLength,
+		MaxLength:      policyConfig.MaxLength,
+		MaxBytes:       policyConfig.MaxBytes,
+		RequireLower:   policyConfig.RequireLower,
+		RequireUpper:   policyConfig.RequireUpper,
+		RequireDigit:   policyConfig.RequireDigit,
+		RequireSymbol:  policyConfig.RequireSymbol,
+		MinCharClasses: policyConfig.MinCharClasses,
+		RejectPersonal: policyConfig.RejectPersonal,
+		RejectCommon:   policyConfig.RejectCommon,
+	}
+
+	switch policyConfig.BreachedSource {
+	case "none":
+	case "range":
+		rangeDir, err := password.NewRangeDir(policyConfig.BreachedPath, policyConfig.BreachedMinCount)
+		if err != nil {
+			panic(err)
+		}
+		policy.Breached = rangeDir
+	case "bloom":
+		bloomFilter, err := password.OpenBloomFilter(policyConfig.BreachedPath)
+		if err != nil {
+			panic(err)
+		}
+		policy.Breached = bloomFilter
+	default:
+		panic(fmt.Sprintf("unknown breached passwords source %q", policyConfig.BreachedSource))
+	}
+
+	return policy
+}
+
+// mustSetupPasswordHasher creates hasher peppering new hashes with configured pepper version
+func mustSetupPasswordHasher(hashConfig config.PasswordHashConfig) *password.PepperedHasher {
+	hasher, err := password.NewHasher(
+		hashConfig.Algorithm,
+		hashConfig.BcryptCost,
+		password.Argon2Params{
+			Memory:      hashConfig.Argon2Memory,
+			Iterations:  hashConfig.Argon2Iterations,
+			Parallelism: hashConfig.Argon2Parallelism,
+			SaltLength:  password.DefaultArgon2Params.SaltLength,
+			KeyLength:   password.DefaultArgon2Params.KeyLength,
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	peppers, err := password.ParsePeppers(hashConfig.Peppers)
+	if err != nil {
+		panic(err)
 	}
+	if hashConfig.PeppersFile != "" {
+		filePeppers, err := password.LoadPeppers(hashConfig.PeppersFile)
+		if err != nil {
+			panic(err)
+		}
+		for version, key := range filePeppers {
+			if _, exists := peppers[version]; exists {
+				panic(fmt.Sprintf("pepper %d is set both in env and file", version))
+			}
+			peppers[version] = key
+		}
+	}
+
+	current := hashConfig.PepperVersion
+	if current == 0 {
+		for version := range peppers {
+			current = max(current, version)
+		}
+	}
+
+	pepperedHasher, err := password.NewPepperedHasher(hasher, peppers, current)
+	if err != nil {
+		panic(err)
+	}
+
+	return pepperedHasher
 }
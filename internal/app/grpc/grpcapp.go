@@ -3,6 +3,9 @@ package grpcapp
 import (
 	authserver "auth-service/internal/grpc/auth"
 	interceptorlogger "auth-service/internal/interceptors"
+	authinterceptor "auth-service/internal/interceptors/auth"
+	localeinterceptor "auth-service/internal/interceptors/locale"
+	"auth-service/internal/lib/clientip"
 	"fmt"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
@@ -24,11 +27,15 @@ func NewGrpcApp(
 	log *slog.Logger,
 	authService authserver.AuthService,
 	userService authserver.UserService,
+	accessService authserver.AccessService,
+	verificationService authserver.VerificationService,
+	trustedProxies clientip.Proxies,
 	port int,
 ) *GrpcApp {
+	// payloads carry passwords, tokens and codes, so only calls and their results are logged
 	loggingOpts := []logging.Option{
 		logging.WithLogOnEvents(
-			logging.PayloadReceived, logging.PayloadSent,
+			logging.StartCall, logging.FinishCall,
 		),
 	}
 	recoveryOpts := []recovery.Option{
@@ -40,9 +47,11 @@ func NewGrpcApp(
 	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
 		recovery.UnaryServerInterceptor(recoveryOpts...),
 		logging.UnaryServerInterceptor(interceptorlogger.InterceptorLogger(log), loggingOpts...),
+		authinterceptor.UnaryServerInterceptor(log, authserver.Policy(), authService, accessService),
+		localeinterceptor.UnaryServerInterceptor(),
 	))
 
-	authserver.RegisterAuthServer(gRPCServer, authService, userService)
+	authserver.RegisterAuthServer(gRPCServer, authService, userService, accessService, verificationService, trustedProxies)
 
 	return &GrpcApp{
 		log:        log,
@@ -0,0 +1,59 @@
+package httpapp
+
+import (
+	"auth-service/internal/lib/sl"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type HttpApp struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+}
+
+func NewHttpApp(
+	log *slog.Logger,
+	handler http.Handler,
+	port int,
+) *HttpApp {
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return &HttpApp{
+		log:        log,
+		httpServer: httpServer,
+		port:       port,
+	}
+}
+
+func (a *HttpApp) MustRun() {
+	const op = "httpapp.MustRun"
+
+	a.log.Info("http server started", slog.String("addr", a.httpServer.Addr))
+
+	if err := a.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(fmt.Errorf("%s: %w", op, err))
+	}
+}
+
+func (a *HttpApp) Stop() {
+	const op = "httpapp.Stop"
+
+	a.log.With(slog.String("op", op)).
+		Info("stopping http server", slog.Int("port", a.port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		a.log.Error("failed to stop http server", sl.Err(err))
+	}
+}
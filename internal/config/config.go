@@ -1,16 +1,30 @@
 package config
 
 import (
+	"auth-service/internal/lib/password"
 	"fmt"
 	"github.com/joho/godotenv"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
 	Env               string // dev || prod
 	GRPC              GRPCConfig
+	HTTP              HTTPConfig
+	TrustedProxies    []string // addresses or CIDRs of proxies allowed to report client address
+	JWT               JWTConfig
+	Mailer            MailerConfig
+	EmailVerification EmailVerificationConfig
+	PasswordReset     PasswordResetConfig
+	LoginCode         LoginCodeConfig
+	OAuth             OAuthConfig
+	PasswordPolicy    PasswordPolicyConfig
+	PasswordHash      PasswordHashConfig
+	Mfa               MfaConfig
+	Webauthn          WebauthnConfig
 	PostgresURL       string
 	AccessTokenTTL    time.Duration
 	RefreshTokenTTL   time.Duration
@@ -23,21 +37,220 @@ type GRPCConfig struct {
 	Timeout time.Duration
 }
 
+type HTTPConfig struct {
+	Port int
+	// IntrospectionClients are client_id -> client_secret pairs allowed to call /introspect,
+	// endpoint is disabled if empty
+	IntrospectionClients map[string]string
+}
+
+type JWTConfig struct {
+	KeysSource         string // file || dir || postgres
+	Algorithm          string // RS256 || ES256 || EdDSA, file source only
+	PrivateKeyPath     string // file source only
+	KeyID              string // RFC 7638 thumbprint is used if empty, file source only
+	KeysDir            string // dir source only
+	KeysGracePeriod    time.Duration
+	KeysReloadInterval time.Duration
+	Issuer             string
+	DefaultAudience    string              // tokens of direct logins
+	OAuthAudience      string              // tokens of OAuth clients missing in ClientAudiences
+	ClientAudiences    map[string][]string // OAuth client_id -> token audiences
+	ClockSkew          time.Duration
+}
+
+type MailerConfig struct {
+	Backend       string // smtp || file || memory || log
+	From          string
+	SMTPHost      string
+	SMTPPort      int
+	SMTPUsername  string
+	SMTPPassword  string
+	SMTPTLS       string        // none || starttls || tls
+	SMTPTimeout   time.Duration // limit of message delivery
+	Dir           string        // file backend only
+	TemplatesDir  string        // overrides embedded templates, <dir>/<locale>/<name>.{subject.txt,txt,html}
+	DefaultLocale string
+}
+
+type EmailVerificationConfig struct {
+	Required       bool // Login is refused until email is verified
+	TokenTTL       time.Duration
+	ResendInterval time.Duration // minimal interval between verification emails sent to one user
+	URL            string        // frontend page verification token is appended to as ?token=
+}
+
+type PasswordResetConfig struct {
+	TokenTTL time.Duration
+	URL      string // frontend page reset token is appended to as ?token=
+}
+
+type LoginCodeConfig struct {
+	TTL            time.Duration
+	MaxAttempts    int           // tries per code, then a new code has to be requested
+	ResendInterval time.Duration // minimal interval between codes sent to one user
+	URL            string        // frontend page email and link token are appended to as ?email=&token=
+	// MaxFailures failed tries across codes lock login by code of user for FailureWindow
+	MaxFailures   int
+	FailureWindow time.Duration
+}
+
+type OAuthConfig struct {
+	LoginURL string        // frontend page authorization request is forwarded to, it logs user in and asks for consent
+	CodeTTL  time.Duration // authorization codes are short-lived, client exchanges them right away
+}
+
+// PasswordPolicyConfig rules apply to new passwords only, zero values disable rules
+type PasswordPolicyConfig struct {
+	MinLength        int // in characters
+	MaxLength        int // in characters
+	MaxBytes         int // bcrypt can not hash more than 72 bytes, argon2id has no limit
+	RequireLower     bool
+	RequireUpper     bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	MinCharClasses   int    // of lowercase, uppercase, digits and symbols
+	RejectPersonal   bool   // password must not contain email or username
+	RejectCommon     bool   // password must not be in embedded list of common passwords
+	BreachedSource   string // none || range (dir of HIBP range files) || bloom (filter built by cmd/breached)
+	BreachedPath     string
+	BreachedMinCount int // range source only, bloom filter is built with its own threshold
+}
+
+// PasswordHashConfig is used for new hashes, older ones are rehashed on successful login
+type PasswordHashConfig struct {
+	Algorithm         string // argon2id || bcrypt
+	BcryptCost        int
+	Argon2Memory      uint32 // KiB
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+	// Peppers are version -> base64 key, PeppersFile has the same pairs as "<version>:<key>" lines
+	Peppers       map[string]string
+	PeppersFile   string
+	PepperVersion int // pepper of new hashes, the highest configured version if 0
+}
+
+type MfaConfig struct {
+	Issuer       string // account issuer shown in authenticator apps
+	SecretKey    string // base64 AES-256 key encrypting TOTP secrets in database, TOTP is disabled if empty
+	ChallengeTTL time.Duration
+	MaxAttempts  int // codes accepted per login challenge
+	// MaxFailures failed codes across challenges lock second factor of user for FailureWindow
+	MaxFailures   int
+	FailureWindow time.Duration
+}
+
+type WebauthnConfig struct {
+	RPID    string   // domain passkeys are bound to, changing it makes registered passkeys unusable
+	RPName  string   // shown by authenticators
+	Origins []string // exact origins of pages calling WebAuthn, e.g. https://app.example.com
+	Timeout time.Duration
+}
+
 func MustLoad() *Config {
 	loadEnvFile()
 
 	env := getEnv("ENV", "dev")
 	grpcPort := getEnvAsInt("GRPC_PORT", 50051)
 	grpcTimeout := getEnvAsDuration("GRPC_TIMEOUT", 10*time.Second)
+	httpPort := getEnvAsInt("HTTP_PORT", 8080)
+	introspectionClients := getEnvAsMap("INTROSPECTION_CLIENTS")
+	trustedProxies := getEnvAsList("TRUSTED_PROXIES", "")
+	jwtKeysSource := getEnv("JWT_KEYS_SOURCE", "file")
+	jwtAlgorithm := getEnv("JWT_ALGORITHM", "RS256")
+	jwtPrivateKeyPath := getEnv("JWT_PRIVATE_KEY_PATH", "")
+	jwtKeyID := getEnv("JWT_KEY_ID", "")
+	jwtKeysDir := getEnv("JWT_KEYS_DIR", "./keys")
+	jwtKeysReloadInterval := getEnvAsDuration("JWT_KEYS_RELOAD_INTERVAL", time.Minute)
+	jwtIssuer := getEnv("JWT_ISSUER", "auth-service")
+	jwtDefaultAudience := getEnv("JWT_AUDIENCE", "smartapiforge")
+	jwtOAuthAudience := getEnv("JWT_OAUTH_AUDIENCE", "smartapiforge-oauth")
+	jwtClientAudiences := make(map[string][]string)
+	for clientID, audiences := range getEnvAsMap("JWT_CLIENT_AUDIENCES") {
+		jwtClientAudiences[clientID] = strings.Split(audiences, "|")
+	}
+	jwtClockSkew := getEnvAsDuration("JWT_CLOCK_SKEW", 30*time.Second)
+	mailerBackend := getEnv("MAILER_BACKEND", "smtp")
+	mailerFrom := getEnv("MAILER_FROM", "SmartAPIForge <no-reply@localhost>")
+	smtpHost := getEnv("SMTP_HOST", "localhost")
+	smtpPort := getEnvAsInt("SMTP_PORT", 587)
+	smtpUsername := getEnv("SMTP_USERNAME", "")
+	smtpPassword := getEnv("SMTP_PASSWORD", "")
+	smtpTLS := getEnv("SMTP_TLS", "starttls")
+	smtpTimeout := getEnvAsDuration("SMTP_TIMEOUT", 30*time.Second)
+	mailerDir := getEnv("MAILER_DIR", "./mail")
+	mailerTemplatesDir := getEnv("MAILER_TEMPLATES_DIR", "")
+	mailerDefaultLocale := getEnv("MAILER_DEFAULT_LOCALE", "en")
+	emailVerificationRequired := getEnvAsBool("EMAIL_VERIFICATION_REQUIRED", false)
+	emailVerificationTTL := getEnvAsDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	emailVerificationResendInterval := getEnvAsDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", time.Minute)
+	emailVerificationURL := getEnv("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email")
+	passwordResetTTL := getEnvAsDuration("PASSWORD_RESET_TTL", time.Hour)
+	passwordResetURL := getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password")
+	loginCodeTTL := getEnvAsDuration("LOGIN_CODE_TTL", 10*time.Minute)
+	loginCodeMaxAttempts := getEnvAsInt("LOGIN_CODE_MAX_ATTEMPTS", 5)
+	loginCodeResendInterval := getEnvAsDuration("LOGIN_CODE_RESEND_INTERVAL", time.Minute)
+	loginCodeURL := getEnv("LOGIN_CODE_URL", "http://localhost:3000/login-code")
+	loginCodeMaxFailures := getEnvAsInt("LOGIN_CODE_MAX_FAILURES", 10)
+	loginCodeFailureWindow := getEnvAsDuration("LOGIN_CODE_FAILURE_WINDOW", 15*time.Minute)
+	oauthLoginURL := getEnv("OAUTH_LOGIN_URL", "http://localhost:3000/oauth/authorize")
+	oauthCodeTTL := getEnvAsDuration("OAUTH_CODE_TTL", time.Minute)
+	passwordMinLength := getEnvAsInt("PASSWORD_MIN_LENGTH", 8)
+	passwordMaxLength := getEnvAsInt("PASSWORD_MAX_LENGTH", 0)
+	passwordMaxBytes := getEnvAsInt("PASSWORD_MAX_BYTES", password.BcryptMaxBytes)
+	passwordRequireLower := getEnvAsBool("PASSWORD_REQUIRE_LOWER", false)
+	passwordRequireUpper := getEnvAsBool("PASSWORD_REQUIRE_UPPER", false)
+	passwordRequireDigit := getEnvAsBool("PASSWORD_REQUIRE_DIGIT", false)
+	passwordRequireSymbol := getEnvAsBool("PASSWORD_REQUIRE_SYMBOL", false)
+	passwordMinCharClasses := getEnvAsInt("PASSWORD_MIN_CHAR_CLASSES", 0)
+	passwordRejectPersonal := getEnvAsBool("PASSWORD_REJECT_PERSONAL", true)
+	passwordRejectCommon := getEnvAsBool("PASSWORD_REJECT_COMMON", true)
+	passwordBreachedSource := getEnv("PASSWORD_BREACHED_SOURCE", "none")
+	passwordBreachedPath := getEnv("PASSWORD_BREACHED_PATH", "")
+	passwordBreachedMinCount := getEnvAsInt("PASSWORD_BREACHED_MIN_COUNT", 1)
+	passwordHashAlgorithm := getEnv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	passwordBcryptCost := getEnvAsInt("PASSWORD_BCRYPT_COST", 10)
+	passwordArgon2Memory := getEnvAsInt("PASSWORD_ARGON2_MEMORY", 19*1024)
+	passwordArgon2Iterations := getEnvAsInt("PASSWORD_ARGON2_ITERATIONS", 2)
+	passwordArgon2Parallelism := getEnvAsInt("PASSWORD_ARGON2_PARALLELISM", 1)
+	passwordPeppers := getEnvAsMap("PASSWORD_PEPPERS")
+	passwordPeppersFile := getEnv("PASSWORD_PEPPERS_FILE", "")
+	passwordPepperVersion := getEnvAsInt("PASSWORD_PEPPER_VERSION", 0)
+	mfaIssuer := getEnv("MFA_ISSUER", "SmartAPIForge")
+	mfaSecretKey := getEnv("MFA_SECRET_KEY", "")
+	mfaChallengeTTL := getEnvAsDuration("MFA_CHALLENGE_TTL", 5*time.Minute)
+	mfaMaxAttempts := getEnvAsInt("MFA_MAX_ATTEMPTS", 5)
+	mfaMaxFailures := getEnvAsInt("MFA_MAX_FAILURES", 10)
+	mfaFailureWindow := getEnvAsDuration("MFA_FAILURE_WINDOW", 15*time.Minute)
+	webauthnRPID := getEnv("WEBAUTHN_RP_ID", "localhost")
+	webauthnRPName := getEnv("WEBAUTHN_RP_NAME", "SmartAPIForge")
+	webauthnOrigins := getEnvAsList("WEBAUTHN_ORIGINS", "http://localhost:3000")
+	webauthnTimeout := getEnvAsDuration("WEBAUTHN_TIMEOUT", 5*time.Minute)
 	postgresURL := buildPostgresURL()
 	accessTokenTTL := getEnvAsDuration("ACCESS_TOKEN_TTL", 30*time.Minute)
 	refreshTokenTTL := getEnvAsDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	jwtKeysGracePeriod := getEnvAsDuration("JWT_KEYS_GRACE_PERIOD", refreshTokenTTL)
 	schemaRegistryUrl := getEnv("SCHEMA_REGISTRY_URL", "http://localhost:6767")
 	kafkaHost := getEnv("KAFKA_HOST", "http://localhost:9092")
 
 	if postgresURL == "" {
 		panic("postgresURL is required but not set")
 	}
+	if jwtKeysSource == "file" && jwtPrivateKeyPath == "" {
+		panic("jwtPrivateKeyPath is required but not set")
+	}
+	if passwordHashAlgorithm == password.AlgorithmBcrypt && (passwordMaxBytes <= 0 || passwordMaxBytes > password.BcryptMaxBytes) {
+		panic(fmt.Sprintf("passwordMaxBytes must be between 1 and %d for bcrypt", password.BcryptMaxBytes))
+	}
+	if passwordArgon2Memory <= 0 || passwordArgon2Iterations <= 0 || passwordArgon2Parallelism <= 0 || passwordArgon2Parallelism > 255 {
+		panic("passwordArgon2 parameters are out of range")
+	}
+	if jwtOAuthAudience == jwtDefaultAudience {
+		panic("jwtOAuthAudience must differ from jwtDefaultAudience")
+	}
+	if passwordBreachedSource != "none" && passwordBreachedPath == "" {
+		panic("passwordBreachedPath is required but not set")
+	}
 
 	return &Config{
 		Env: env,
@@ -45,6 +258,99 @@ func MustLoad() *Config {
 			Port:    grpcPort,
 			Timeout: grpcTimeout,
 		},
+		HTTP: HTTPConfig{
+			Port:                 httpPort,
+			IntrospectionClients: introspectionClients,
+		},
+		TrustedProxies: trustedProxies,
+		JWT: JWTConfig{
+			KeysSource:         jwtKeysSource,
+			Algorithm:          jwtAlgorithm,
+			PrivateKeyPath:     jwtPrivateKeyPath,
+			KeyID:              jwtKeyID,
+			KeysDir:            jwtKeysDir,
+			KeysGracePeriod:    jwtKeysGracePeriod,
+			KeysReloadInterval: jwtKeysReloadInterval,
+			Issuer:             jwtIssuer,
+			DefaultAudience:    jwtDefaultAudience,
+			OAuthAudience:      jwtOAuthAudience,
+			ClientAudiences:    jwtClientAudiences,
+			ClockSkew:          jwtClockSkew,
+		},
+		Mailer: MailerConfig{
+			Backend:       mailerBackend,
+			From:          mailerFrom,
+			SMTPHost:      smtpHost,
+			SMTPPort:      smtpPort,
+			SMTPUsername:  smtpUsername,
+			SMTPPassword:  smtpPassword,
+			SMTPTLS:       smtpTLS,
+			SMTPTimeout:   smtpTimeout,
+			Dir:           mailerDir,
+			TemplatesDir:  mailerTemplatesDir,
+			DefaultLocale: mailerDefaultLocale,
+		},
+		EmailVerification: EmailVerificationConfig{
+			Required:       emailVerificationRequired,
+			TokenTTL:       emailVerificationTTL,
+			ResendInterval: emailVerificationResendInterval,
+			URL:            emailVerificationURL,
+		},
+		PasswordReset: PasswordResetConfig{
+			TokenTTL: passwordResetTTL,
+			URL:      passwordResetURL,
+		},
+		LoginCode: LoginCodeConfig{
+			TTL:            loginCodeTTL,
+			MaxAttempts:    loginCodeMaxAttempts,
+			ResendInterval: loginCodeResendInterval,
+			URL:            loginCodeURL,
+			MaxFailures:    loginCodeMaxFailures,
+			FailureWindow:  loginCodeFailureWindow,
+		},
+		OAuth: OAuthConfig{
+			LoginURL: oauthLoginURL,
+			CodeTTL:  oauthCodeTTL,
+		},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:        passwordMinLength,
+			MaxLength:        passwordMaxLength,
+			MaxBytes:         passwordMaxBytes,
+			RequireLower:     passwordRequireLower,
+			RequireUpper:     passwordRequireUpper,
+			RequireDigit:     passwordRequireDigit,
+			RequireSymbol:    passwordRequireSymbol,
+			MinCharClasses:   passwordMinCharClasses,
+			RejectPersonal:   passwordRejectPersonal,
+			RejectCommon:     passwordRejectCommon,
+			BreachedSource:   passwordBreachedSource,
+			BreachedPath:     passwordBreachedPath,
+			BreachedMinCount: passwordBreachedMinCount,
+		},
+		PasswordHash: PasswordHashConfig{
+			Algorithm:         passwordHashAlgorithm,
+			BcryptCost:        passwordBcryptCost,
+			Argon2Memory:      uint32(passwordArgon2Memory),
+			Argon2Iterations:  uint32(passwordArgon2Iterations),
+			Argon2Parallelism: uint8(passwordArgon2Parallelism),
+			Peppers:           passwordPeppers,
+			PeppersFile:       passwordPeppersFile,
+			PepperVersion:     passwordPepperVersion,
+		},
+		Mfa: MfaConfig{
+			Issuer:        mfaIssuer,
+			SecretKey:     mfaSecretKey,
+			ChallengeTTL:  mfaChallengeTTL,
+			MaxAttempts:   mfaMaxAttempts,
+			MaxFailures:   mfaMaxFailures,
+			FailureWindow: mfaFailureWindow,
+		},
+		Webauthn: WebauthnConfig{
+			RPID:    webauthnRPID,
+			RPName:  webauthnRPName,
+			Origins: webauthnOrigins,
+			Timeout: webauthnTimeout,
+		},
 		PostgresURL:       postgresURL,
 		AccessTokenTTL:    accessTokenTTL,
 		RefreshTokenTTL:   refreshTokenTTL,
@@ -74,6 +380,14 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	valueStr := getEnv(key, "")
 	if value, err := time.ParseDuration(valueStr); err == nil {
@@ -82,6 +396,29 @@ func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// getEnvAsMap parses "key1:value1,key2:value2" list
+func getEnvAsMap(key string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(getEnv(key, ""), ",") {
+		k, v, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if found && k != "" {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// getEnvAsList parses "value1,value2" list, empty values are skipped
+func getEnvAsList(key string, defaultValue string) []string {
+	var result []string
+	for _, value := range strings.Split(getEnv(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
 func buildPostgresURL() string {
 	user := getEnv("POSTGRES_USER", "postgres")
 	password := getEnv("POSTGRES_PASSWORD", "postgres")
@@ -0,0 +1,20 @@
+package models
+
+const (
+	TokenTypeHintAccess  = "access_token"
+	TokenTypeHintRefresh = "refresh_token"
+)
+
+// TokenIntrospection is RFC 7662 introspection result, inactive token has only Active = false
+type TokenIntrospection struct {
+	Active    bool
+	Subject   string
+	Email     string
+	ExpiresAt int64
+	IssuedAt  int64
+	Scope     string
+	ClientID  string
+	TokenType string
+	Role      int64
+	Roles     []int64
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginCode is a pending passwordless login, neither code nor link token is stored
+type LoginCode struct {
+	ID        int64     `db:"id"`
+	UserID    int64     `db:"user_id"`
+	CodeHash  string    `db:"code_hash"`
+	TokenHash string    `db:"token_hash"`
+	ExpiresAt time.Time `db:"expires_at"`
+	Attempts  int       `db:"attempts"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Totp is authenticator app enrollment of user, Secret is encrypted
+type Totp struct {
+	UserID       int64      `db:"user_id"`
+	Secret       []byte     `db:"secret"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	LastUsedStep int64      `db:"last_used_step"`
+}
+
+// MfaChallenge is a login waiting for the second factor after password was checked, token itself is never stored
+type MfaChallenge struct {
+	ID        int64     `db:"id"`
+	UserID    int64     `db:"user_id"`
+	TokenHash string    `db:"token_hash"`
+	ExpiresAt time.Time `db:"expires_at"`
+	Attempts  int       `db:"attempts"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// OAuthClient is a registered application, SecretHash is empty for public clients
+type OAuthClient struct {
+	ID           string
+	Name         string
+	SecretHash   string
+	RedirectURIs []string
+	Scopes       []string
+	// FirstParty clients are trusted, users are not asked for consent
+	FirstParty bool
+	CreatedAt  time.Time
+}
+
+// Public clients can not keep secret, they authenticate with PKCE verifier only
+func (c OAuthClient) Public() bool {
+	return c.SecretHash == ""
+}
+
+// OAuthCode is an issued authorization code, the code itself is not stored
+type OAuthCode struct {
+	ID            int64     `db:"id"`
+	CodeHash      string    `db:"code_hash"`
+	ClientID      string    `db:"client_id"`
+	UserID        int64     `db:"user_id"`
+	RedirectURI   string    `db:"redirect_uri"`
+	Scope         string    `db:"scope"`
+	CodeChallenge string    `db:"code_challenge"`
+	SessionID     *string   `db:"session_id"`
+	ExpiresAt     time.Time `db:"expires_at"`
+	// Nonce and AuthTime go to ID token, AuthTime is start of the session user approved request in
+	Nonce    string     `db:"nonce"`
+	AuthTime *time.Time `db:"auth_time"`
+}
+
+// AuthorizationRequest is RFC 6749 section 4.1.1 request with RFC 7636 PKCE parameters
+// and OpenID Connect nonce
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// AuthorizationPrompt is shown to user on consent page
+type AuthorizationPrompt struct {
+	ClientID   string
+	ClientName string
+	Scopes     []string
+	// ConsentRequired is unset when user already granted the scopes or client is first party
+	ConsentRequired bool
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"slices"
+	"strconv"
+)
+
+// standard OpenID Connect scopes, every client may request them
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var OIDCScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+// UserInfo is OpenID Connect claims about user, fields of scopes which were not granted are empty
+type UserInfo struct {
+	Subject           string
+	PreferredUsername string
+	Email             string
+	// EmailVerified is set together with Email
+	EmailVerified *bool
+}
+
+// NewUserInfo maps user fields to claims of granted scopes: profile releases username, email releases email
+func NewUserInfo(user User, scopes []string) UserInfo {
+	info := UserInfo{Subject: strconv.FormatInt(user.ID, 10)}
+
+	if slices.Contains(scopes, ScopeProfile) {
+		info.PreferredUsername = user.Username
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		info.Email = user.Email
+		info.EmailVerified = &user.EmailVerified
+	}
+
+	return info
+}
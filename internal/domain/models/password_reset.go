@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// PasswordReset is a pending reset of user password, token itself is never stored
+type PasswordReset struct {
+	UserID    int64     `db:"user_id"`
+	TokenHash string    `db:"token_hash"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// permissions the service itself checks, they are created by cmd/seed
+const (
+	PermissionUsersRead      = "users:read"
+	PermissionUsersDelete    = "users:delete"
+	PermissionSessionsRevoke = "sessions:revoke"
+	PermissionRolesManage    = "roles:manage"
+)
+
+// Role inherits every permission of its parent, so parent role checks pass for it too
+type Role struct {
+	ID       int64
+	Name     string
+	ParentID *int64 `db:"parent_id"`
+	// Permissions granted to role directly, without inherited ones
+	Permissions []string `db:"-"`
+}
+
+type Permission struct {
+	ID          int64
+	Name        string
+	Description string
+}
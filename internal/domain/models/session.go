@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Session is a chain of refresh tokens started by one login, its ID is the token family ID
+type Session struct {
+	ID              string
+	UserID          int64      `db:"user_id"`
+	UserAgent       string     `db:"user_agent"`
+	IPAddress       string     `db:"ip_address"`
+	CreatedAt       time.Time  `db:"created_at"`
+	LastRefreshedAt *time.Time `db:"last_refreshed_at"`
+	RevokedAt       *time.Time `db:"revoked_at"`
+	// Current is set when session is the one of the token it was requested with
+	Current bool `db:"-"`
+}
+
+// ClientInfo describes device session is started from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+type RefreshToken struct {
+	ID        int64
+	SessionID string    `db:"session_id"`
+	UserID    int64     `db:"user_id"`
+	TokenHash string    `db:"token_hash"`
+	ExpiresAt time.Time `db:"expires_at"`
+	// access token issued together with the refresh token, it is denylisted when session is revoked
+	AccessTokenID        string    `db:"access_jti"`
+	AccessTokenExpiresAt time.Time `db:"access_expires_at"`
+	Used                 bool      `db:"used"`
+	// SessionRevoked is set when the whole token family was revoked
+	SessionRevoked bool `db:"session_revoked"`
+}
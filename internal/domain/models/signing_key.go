@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+const (
+	KeyStatusPending = "pending" // published in JWKS, not used for signing yet
+	KeyStatusActive  = "active"  // signs new tokens
+	KeyStatusRetired = "retired" // verifies already issued tokens until VerifyUntil
+)
+
+type SigningKey struct {
+	ID          string `db:"kid"`
+	Algorithm   string
+	PrivateKey  []byte `db:"private_key"` // PEM encoded
+	Status      string
+	CreatedAt   time.Time  `db:"created_at"`
+	RetiredAt   *time.Time `db:"retired_at"`
+	VerifyUntil *time.Time `db:"verify_until"`
+}
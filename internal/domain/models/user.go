@@ -1,9 +1,17 @@
 package models
 
+const (
+	// RoleAdmin is the id of admin role created by cmd/seed
+	RoleAdmin int64 = 1
+	// RoleCustomer is the id of customer role created by cmd/seed, it is default role of new users
+	RoleCustomer int64 = 2
+)
+
 type User struct {
-	ID       int64
-	Username string
-	Email    string
-	Password []byte
-	Role     int64 `db:"role_id"`
+	ID            int64
+	Username      string
+	Email         string
+	Password      []byte
+	Roles         []int64
+	EmailVerified bool
 }
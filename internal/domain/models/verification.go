@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// EmailVerification is a pending confirmation of user email, token itself is never stored
+type EmailVerification struct {
+	UserID    int64     `db:"user_id"`
+	Email     string    `db:"email"`
+	TokenHash string    `db:"token_hash"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// WebAuthn ceremonies challenges are issued for
+const (
+	WebauthnCeremonyRegistration = "registration"
+	WebauthnCeremonyLogin        = "login"
+)
+
+// WebauthnCredential is passkey or security key of user, PublicKey is COSE_Key
+type WebauthnCredential struct {
+	ID             int64
+	UserID         int64
+	CredentialID   []byte
+	PublicKey      []byte
+	SignCount      int64
+	AAGUID         []byte
+	Transports     []string
+	Name           string
+	BackupEligible bool
+	BackedUp       bool
+	CreatedAt      time.Time
+	LastUsedAt     *time.Time
+}
+
+// WebauthnChallenge is started ceremony, UserID is nil for passwordless login
+type WebauthnChallenge struct {
+	ID            int64     `db:"id"`
+	UserID        *int64    `db:"user_id"`
+	ChallengeHash string    `db:"challenge_hash"`
+	Ceremony      string    `db:"ceremony"`
+	ExpiresAt     time.Time `db:"expires_at"`
+}
@@ -0,0 +1,30 @@
+package authserver
+
+import (
+	"auth-service/internal/domain/models"
+	"context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// clientInfo extracts caller device from request metadata.
+// Address set by a trusted proxy in x-forwarded-for or x-real-ip wins over the peer one
+func (s *AuthServer) clientInfo(ctx context.Context) models.ClientInfo {
+	var client models.ClientInfo
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("user-agent"); len(values) > 0 {
+		client.UserAgent = values[0]
+	}
+
+	var peerAddress, realIP string
+	if p, ok := peer.FromContext(ctx); ok {
+		peerAddress = p.Addr.String()
+	}
+	if values := md.Get("x-real-ip"); len(values) > 0 {
+		realIP = values[0]
+	}
+	client.IPAddress = s.trustedProxies.Resolve(peerAddress, md.Get("x-forwarded-for"), realIP)
+
+	return client
+}
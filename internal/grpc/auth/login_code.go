@@ -0,0 +1,53 @@
+package authserver
+
+import (
+	authservice "auth-service/internal/services/auth"
+	"context"
+	"errors"
+	authProto "github.com/SmartAPIForge/protos/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// RequestLoginCode responds the same way whether email is registered or not
+func (s *AuthServer) RequestLoginCode(
+	ctx context.Context,
+	in *authProto.RequestLoginCodeRequest,
+) (*emptypb.Empty, error) {
+	if in.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	if err := s.authService.RequestLoginCode(ctx, in.Email); err != nil {
+		return nil, status.Error(codes.Internal, "failed to request login code")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// LoginWithCode accepts either the code or the link token from email, response is the same as of Login
+func (s *AuthServer) LoginWithCode(
+	ctx context.Context,
+	in *authProto.LoginWithCodeRequest,
+) (*authProto.LoginResponse, error) {
+	if in.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+	if in.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	result, err := s.authService.LoginWithCode(ctx, in.Email, in.Code, s.clientInfo(ctx))
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidLoginCode) {
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired code")
+		}
+		if errors.Is(err, authservice.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "too many failed attempts, try again later")
+		}
+		return nil, status.Error(codes.Internal, "failed to login")
+	}
+
+	return loginResponse(result), nil
+}
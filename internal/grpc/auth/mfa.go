@@ -0,0 +1,134 @@
+package authserver
+
+import (
+	authservice "auth-service/internal/services/auth"
+	"context"
+	"errors"
+	authProto "github.com/SmartAPIForge/protos/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *AuthServer) BeginTotpEnrollment(
+	ctx context.Context,
+	in *authProto.BeginTotpEnrollmentRequest,
+) (*authProto.BeginTotpEnrollmentResponse, error) {
+	token, err := accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, uri, err := s.authService.BeginTotpEnrollment(ctx, token)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if errors.Is(err, authservice.ErrMfaEnabled) {
+			return nil, status.Error(codes.FailedPrecondition, "totp is already enabled")
+		}
+		if errors.Is(err, authservice.ErrTotpDisabled) {
+			return nil, status.Error(codes.FailedPrecondition, "totp is disabled")
+		}
+		return nil, status.Error(codes.Internal, "failed to begin totp enrollment")
+	}
+
+	return &authProto.BeginTotpEnrollmentResponse{
+		Secret:     secret,
+		OtpauthUri: uri,
+	}, nil
+}
+
+// ConfirmTotp responds with recovery codes, the only time they are shown
+func (s *AuthServer) ConfirmTotp(
+	ctx context.Context,
+	in *authProto.ConfirmTotpRequest,
+) (*authProto.ConfirmTotpResponse, error) {
+	token, err := accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if in.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	recoveryCodes, err := s.authService.ConfirmTotp(ctx, token, in.Code)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if errors.Is(err, authservice.ErrMfaNotEnrolled) {
+			return nil, status.Error(codes.FailedPrecondition, "totp enrollment is not started")
+		}
+		if errors.Is(err, authservice.ErrMfaEnabled) {
+			return nil, status.Error(codes.FailedPrecondition, "totp is already enabled")
+		}
+		if errors.Is(err, authservice.ErrInvalidMfaCode) {
+			return nil, status.Error(codes.InvalidArgument, "invalid code")
+		}
+		if errors.Is(err, authservice.ErrTotpDisabled) {
+			return nil, status.Error(codes.FailedPrecondition, "totp is disabled")
+		}
+		return nil, status.Error(codes.Internal, "failed to confirm totp")
+	}
+
+	return &authProto.ConfirmTotpResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// RegenerateRecoveryCodes invalidates all previous recovery codes of the caller
+func (s *AuthServer) RegenerateRecoveryCodes(
+	ctx context.Context,
+	in *authProto.RegenerateRecoveryCodesRequest,
+) (*authProto.RegenerateRecoveryCodesResponse, error) {
+	token, err := accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := s.authService.RegenerateRecoveryCodes(ctx, token)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if errors.Is(err, authservice.ErrMfaNotEnrolled) {
+			return nil, status.Error(codes.FailedPrecondition, "totp is not enabled")
+		}
+		return nil, status.Error(codes.Internal, "failed to regenerate recovery codes")
+	}
+
+	return &authProto.RegenerateRecoveryCodesResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// CompleteMfaLogin finishes Login which responded with mfa_required
+func (s *AuthServer) CompleteMfaLogin(
+	ctx context.Context,
+	in *authProto.CompleteMfaLoginRequest,
+) (*authProto.LoginResponse, error) {
+	if in.MfaToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "mfa token is required")
+	}
+	if in.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	accessToken, refreshToken, err := s.authService.CompleteMfaLogin(ctx, in.MfaToken, in.Code, s.clientInfo(ctx))
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidMfaToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired mfa token")
+		}
+		if errors.Is(err, authservice.ErrInvalidMfaCode) {
+			return nil, status.Error(codes.InvalidArgument, "invalid code")
+		}
+		if errors.Is(err, authservice.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "too many failed attempts, try again later")
+		}
+		if errors.Is(err, authservice.ErrTotpDisabled) {
+			return nil, status.Error(codes.FailedPrecondition, "totp is disabled")
+		}
+		return nil, status.Error(codes.Internal, "failed to complete login")
+	}
+
+	return &authProto.LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
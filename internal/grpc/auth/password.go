@@ -0,0 +1,114 @@
+package authserver
+
+import (
+	"auth-service/internal/lib/password"
+	authservice "auth-service/internal/services/auth"
+	"context"
+	"errors"
+	authProto "github.com/SmartAPIForge/protos/gen/go/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// RequestPasswordReset responds the same way whether email is registered or not
+func (s *AuthServer) RequestPasswordReset(
+	ctx context.Context,
+	in *authProto.RequestPasswordResetRequest,
+) (*emptypb.Empty, error) {
+	if in.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	if err := s.authService.RequestPasswordReset(ctx, in.Email); err != nil {
+		return nil, status.Error(codes.Internal, "failed to request password reset")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) ResetPassword(
+	ctx context.Context,
+	in *authProto.ResetPasswordRequest,
+) (*emptypb.Empty, error) {
+	if in.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	if in.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "new password is required")
+	}
+
+	if err := s.authService.ResetPassword(ctx, in.Token, in.NewPassword); err != nil {
+		if errors.Is(err, authservice.ErrInvalidResetToken) {
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired reset token")
+		}
+		if policyErr := passwordPolicyError(err, "new_password"); policyErr != nil {
+			return nil, policyErr
+		}
+		return nil, status.Error(codes.Internal, "failed to reset password")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) ChangePassword(
+	ctx context.Context,
+	in *authProto.ChangePasswordRequest,
+) (*emptypb.Empty, error) {
+	token, err := accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if in.OldPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "old password is required")
+	}
+	if in.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "new password is required")
+	}
+
+	err = s.authService.ChangePassword(ctx, token, in.OldPassword, in.NewPassword, in.KeepCurrentSession)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if errors.Is(err, authservice.ErrInvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, "invalid old password")
+		}
+		if errors.Is(err, authservice.ErrSamePassword) {
+			return nil, status.Error(codes.InvalidArgument, "new password must differ from the old one")
+		}
+		if policyErr := passwordPolicyError(err, "new_password"); policyErr != nil {
+			return nil, policyErr
+		}
+		return nil, status.Error(codes.Internal, "failed to change password")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// passwordPolicyError converts password rejected by policy into InvalidArgument status
+// with BadRequest violation of field per failed rule, returns nil for other errors
+func passwordPolicyError(err error, field string) error {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return nil
+	}
+
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(policyErr.Violations))
+	for _, v := range policyErr.Violations {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: v.Description,
+			Reason:      v.Rule,
+		})
+	}
+
+	st, detailsErr := status.New(codes.InvalidArgument, policyErr.Error()).
+		WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, policyErr.Error())
+	}
+
+	return st.Err()
+}
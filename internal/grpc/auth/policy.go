@@ -0,0 +1,71 @@
+package authserver
+
+import (
+	"auth-service/internal/domain/models"
+	authinterceptor "auth-service/internal/interceptors/auth"
+	authProto "github.com/SmartAPIForge/protos/gen/go/auth"
+)
+
+// Policy declares access level and required permission of every Auth RPC
+func Policy() authinterceptor.Policy {
+	rules := map[string]authinterceptor.Rule{
+		// tokens passed to ValidateUser, ValidatePermission and Introspect are the checked ones, not caller's
+		"Register":           {Access: authinterceptor.Public},
+		"Login":              {Access: authinterceptor.Public},
+		"Refresh":            {Access: authinterceptor.Public},
+		"Logout":             {Access: authinterceptor.Public},
+		"ValidateUser":       {Access: authinterceptor.Public},
+		"ValidatePermission": {Access: authinterceptor.Public},
+		"Introspect":         {Access: authinterceptor.Public},
+		"GetJwks":            {Access: authinterceptor.Public},
+
+		"SendVerificationEmail": {Access: authinterceptor.Public},
+		"VerifyEmail":           {Access: authinterceptor.Public},
+		"RequestPasswordReset":  {Access: authinterceptor.Public},
+		"ResetPassword":         {Access: authinterceptor.Public},
+		"RequestLoginCode":      {Access: authinterceptor.Public},
+		"LoginWithCode":         {Access: authinterceptor.Public},
+		"CompleteMfaLogin":      {Access: authinterceptor.Public},
+		"BeginWebauthnLogin":    {Access: authinterceptor.Public},
+		"FinishWebauthnLogin":   {Access: authinterceptor.Public},
+
+		"GetUserByToken": {Access: authinterceptor.Authenticated},
+		"LogoutAll":      {Access: authinterceptor.Authenticated},
+		"ListSessions":   {Access: authinterceptor.Authenticated},
+		"RevokeSession":  {Access: authinterceptor.Authenticated},
+		"ChangePassword": {Access: authinterceptor.Authenticated},
+
+		"RevokeUserSessions": {Access: authinterceptor.Authenticated, Permission: models.PermissionSessionsRevoke},
+		"CreateRole":         {Access: authinterceptor.Authenticated, Permission: models.PermissionRolesManage},
+		"ListRoles":          {Access: authinterceptor.Authenticated, Permission: models.PermissionRolesManage},
+		"UpdateRole":         {Access: authinterceptor.Authenticated, Permission: models.PermissionRolesManage},
+		"DeleteRole":         {Access: authinterceptor.Authenticated, Permission: models.PermissionRolesManage},
+		"AssignRole":         {Access: authinterceptor.Authenticated, Permission: models.PermissionRolesManage},
+		"RevokeRole":         {Access: authinterceptor.Authenticated, Permission: models.PermissionRolesManage},
+		"GetUsers":           {Access: authinterceptor.Authenticated, Permission: models.PermissionUsersRead},
+		"DeleteUser": {
+			Access:     authinterceptor.SelfOr,
+			Permission: models.PermissionUsersDelete,
+			Owner: func(req any) string {
+				if r, ok := req.(*authProto.DeleteUserRequest); ok {
+					return r.Username
+				}
+				return ""
+			},
+		},
+
+		"BeginTotpEnrollment":     {Access: authinterceptor.Authenticated},
+		"ConfirmTotp":             {Access: authinterceptor.Authenticated},
+		"RegenerateRecoveryCodes": {Access: authinterceptor.Authenticated},
+
+		"BeginWebauthnRegistration":  {Access: authinterceptor.Authenticated},
+		"FinishWebauthnRegistration": {Access: authinterceptor.Authenticated},
+	}
+
+	policy := make(authinterceptor.Policy, len(rules))
+	for method, rule := range rules {
+		policy["/"+authProto.Auth_ServiceDesc.ServiceName+"/"+method] = rule
+	}
+
+	return policy
+}
@@ -0,0 +1,168 @@
+package authserver
+
+import (
+	"auth-service/internal/domain/models"
+	accessservice "auth-service/internal/services/access"
+	"auth-service/internal/storage"
+	"context"
+	"errors"
+	authProto "github.com/SmartAPIForge/protos/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func (s *AuthServer) CreateRole(
+	ctx context.Context,
+	in *authProto.CreateRoleRequest,
+) (*authProto.Role, error) {
+	if in.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	role := roleFromProto(0, in.Name, in.ParentId, in.Permissions)
+	id, err := s.accessService.CreateRole(ctx, role)
+	if err != nil {
+		return nil, roleStatus(err, "failed to create role")
+	}
+	role.ID = id
+
+	return roleToProto(role), nil
+}
+
+func (s *AuthServer) ListRoles(
+	ctx context.Context,
+	_ *authProto.ListRolesRequest,
+) (*authProto.ListRolesResponse, error) {
+	roles, err := s.accessService.ListRoles(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to list roles")
+	}
+
+	protoRoles := make([]*authProto.Role, 0, len(roles))
+	for _, role := range roles {
+		protoRoles = append(protoRoles, roleToProto(role))
+	}
+
+	return &authProto.ListRolesResponse{Roles: protoRoles}, nil
+}
+
+func (s *AuthServer) UpdateRole(
+	ctx context.Context,
+	in *authProto.UpdateRoleRequest,
+) (*authProto.Role, error) {
+	if in.RoleId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "role id is required")
+	}
+	if in.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	role := roleFromProto(in.RoleId, in.Name, in.ParentId, in.Permissions)
+	if err := s.accessService.UpdateRole(ctx, role); err != nil {
+		return nil, roleStatus(err, "failed to update role")
+	}
+
+	return roleToProto(role), nil
+}
+
+func (s *AuthServer) DeleteRole(
+	ctx context.Context,
+	in *authProto.DeleteRoleRequest,
+) (*emptypb.Empty, error) {
+	if in.RoleId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "role id is required")
+	}
+
+	if err := s.accessService.DeleteRole(ctx, in.RoleId); err != nil {
+		return nil, roleStatus(err, "failed to delete role")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) AssignRole(
+	ctx context.Context,
+	in *authProto.AssignRoleRequest,
+) (*emptypb.Empty, error) {
+	if in.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+	if in.RoleId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "role id is required")
+	}
+
+	if err := s.accessService.AssignRole(ctx, in.Username, in.RoleId); err != nil {
+		return nil, roleStatus(err, "failed to assign role")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) RevokeRole(
+	ctx context.Context,
+	in *authProto.RevokeRoleRequest,
+) (*emptypb.Empty, error) {
+	if in.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+	if in.RoleId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "role id is required")
+	}
+
+	if err := s.accessService.RevokeRole(ctx, in.Username, in.RoleId); err != nil {
+		return nil, roleStatus(err, "failed to revoke role")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func roleFromProto(id int64, name string, parentID int64, permissions []string) models.Role {
+	role := models.Role{
+		ID:          id,
+		Name:        name,
+		Permissions: permissions,
+	}
+	if parentID != 0 {
+		role.ParentID = &parentID
+	}
+
+	return role
+}
+
+func roleToProto(role models.Role) *authProto.Role {
+	protoRole := &authProto.Role{
+		Id:          role.ID,
+		Name:        role.Name,
+		Permissions: role.Permissions,
+	}
+	if role.ParentID != nil {
+		protoRole.ParentId = *role.ParentID
+	}
+
+	return protoRole
+}
+
+// roleStatus maps role management errors to gRPC status
+func roleStatus(err error, internalMsg string) error {
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, storage.ErrRoleNotFound):
+		return status.Error(codes.NotFound, "role not found")
+	case errors.Is(err, storage.ErrRoleNotAssigned):
+		return status.Error(codes.NotFound, "role is not assigned to user")
+	case errors.Is(err, storage.ErrRoleExists):
+		return status.Error(codes.AlreadyExists, "role already exists")
+	case errors.Is(err, storage.ErrRoleInUse):
+		return status.Error(codes.FailedPrecondition, "role is assigned to users")
+	case errors.Is(err, storage.ErrLastAdmin):
+		return status.Error(codes.FailedPrecondition, "last admin can not lose admin role")
+	case errors.Is(err, accessservice.ErrRoleCycle):
+		return status.Error(codes.InvalidArgument, "role can not inherit from itself")
+	case errors.Is(err, accessservice.ErrBuiltinRole):
+		return status.Error(codes.FailedPrecondition, "builtin role can not be deleted")
+	default:
+		return status.Error(codes.Internal, internalMsg)
+	}
+}
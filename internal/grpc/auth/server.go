@@ -2,6 +2,8 @@ package authserver
 
 import (
 	"auth-service/internal/domain/models"
+	authinterceptor "auth-service/internal/interceptors/auth"
+	"auth-service/internal/lib/clientip"
 	"auth-service/internal/lib/jwt"
 	authservice "auth-service/internal/services/auth"
 	"auth-service/internal/storage"
@@ -12,6 +14,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type AuthService interface {
@@ -24,11 +27,98 @@ type AuthService interface {
 		ctx context.Context,
 		email string,
 		password string,
-	) (accessToken string, refreshToken string, err error)
+		client models.ClientInfo,
+	) (authservice.LoginResult, error)
 	Refresh(
 		ctx context.Context,
 		refreshToken string,
 	) (string, string, error)
+	ParseAccessToken(
+		ctx context.Context,
+		accessToken string,
+	) (*jwt.Claims, error)
+	Logout(
+		ctx context.Context,
+		refreshToken string,
+	) error
+	LogoutAll(
+		ctx context.Context,
+		accessToken string,
+	) error
+	RevokeUserSessions(
+		ctx context.Context,
+		username string,
+	) error
+	Introspect(
+		ctx context.Context,
+		token string,
+		tokenTypeHint string,
+	) (models.TokenIntrospection, error)
+	RequestPasswordReset(
+		ctx context.Context,
+		email string,
+	) error
+	RequestLoginCode(
+		ctx context.Context,
+		email string,
+	) error
+	LoginWithCode(
+		ctx context.Context,
+		email string,
+		code string,
+		client models.ClientInfo,
+	) (authservice.LoginResult, error)
+	ResetPassword(
+		ctx context.Context,
+		token string,
+		newPassword string,
+	) error
+	ChangePassword(
+		ctx context.Context,
+		accessToken string,
+		oldPassword string,
+		newPassword string,
+		keepCurrentSession bool,
+	) error
+	BeginTotpEnrollment(
+		ctx context.Context,
+		accessToken string,
+	) (secret string, uri string, err error)
+	ConfirmTotp(
+		ctx context.Context,
+		accessToken string,
+		code string,
+	) (recoveryCodes []string, err error)
+	RegenerateRecoveryCodes(
+		ctx context.Context,
+		accessToken string,
+	) ([]string, error)
+	CompleteMfaLogin(
+		ctx context.Context,
+		mfaToken string,
+		code string,
+		client models.ClientInfo,
+	) (accessToken string, refreshToken string, err error)
+	BeginWebauthnRegistration(
+		ctx context.Context,
+		accessToken string,
+	) (optionsJSON string, err error)
+	FinishWebauthnRegistration(
+		ctx context.Context,
+		accessToken string,
+		response string,
+		name string,
+	) (credentialID int64, err error)
+	BeginWebauthnLogin(
+		ctx context.Context,
+		mfaToken string,
+	) (optionsJSON string, err error)
+	FinishWebauthnLogin(
+		ctx context.Context,
+		response string,
+		mfaToken string,
+		client models.ClientInfo,
+	) (accessToken string, refreshToken string, err error)
 }
 
 type UserService interface {
@@ -45,22 +135,91 @@ type UserService interface {
 		ctx context.Context,
 		username string,
 	) error
+	ListSessions(
+		ctx context.Context,
+		accessToken string,
+	) ([]models.Session, error)
+	RevokeSession(
+		ctx context.Context,
+		accessToken string,
+		sessionID string,
+	) error
+}
+
+type AccessService interface {
+	HasRole(
+		ctx context.Context,
+		roleIDs []int64,
+		requiredRole int64,
+	) (bool, error)
+	HasPermissions(
+		ctx context.Context,
+		roleIDs []int64,
+		anyOf []string,
+		allOf []string,
+	) (bool, error)
+	CreateRole(
+		ctx context.Context,
+		role models.Role,
+	) (int64, error)
+	ListRoles(
+		ctx context.Context,
+	) ([]models.Role, error)
+	UpdateRole(
+		ctx context.Context,
+		role models.Role,
+	) error
+	DeleteRole(
+		ctx context.Context,
+		roleID int64,
+	) error
+	AssignRole(
+		ctx context.Context,
+		username string,
+		roleID int64,
+	) error
+	RevokeRole(
+		ctx context.Context,
+		username string,
+		roleID int64,
+	) error
+}
+
+type VerificationService interface {
+	SendVerificationEmail(
+		ctx context.Context,
+		email string,
+	) error
+	VerifyEmail(
+		ctx context.Context,
+		token string,
+	) error
 }
 
 type AuthServer struct {
 	authProto.UnimplementedAuthServer
-	authService AuthService
-	userService UserService
+	authService         AuthService
+	userService         UserService
+	accessService       AccessService
+	verificationService VerificationService
+	// trustedProxies may report client address in x-forwarded-for and x-real-ip
+	trustedProxies clientip.Proxies
 }
 
 func RegisterAuthServer(
 	gRPCServer *grpc.Server,
 	auth AuthService,
 	user UserService,
+	access AccessService,
+	verification VerificationService,
+	trustedProxies clientip.Proxies,
 ) {
 	authProto.RegisterAuthServer(gRPCServer, &AuthServer{
-		authService: auth,
-		userService: user,
+		authService:         auth,
+		userService:         user,
+		accessService:       access,
+		verificationService: verification,
+		trustedProxies:      trustedProxies,
 	})
 }
 
@@ -80,6 +239,9 @@ func (s *AuthServer) Register(
 		if errors.Is(err, storage.ErrUserExists) {
 			return nil, status.Error(codes.AlreadyExists, "user already exists")
 		}
+		if policyErr := passwordPolicyError(err, "password"); policyErr != nil {
+			return nil, policyErr
+		}
 
 		return nil, status.Error(codes.Internal, "failed to register user")
 	}
@@ -98,23 +260,38 @@ func (s *AuthServer) Login(
 		return nil, status.Error(codes.InvalidArgument, "password is required")
 	}
 
-	accessToken, refreshToken, err := s.authService.Login(ctx, in.GetEmail(), in.GetPassword())
+	result, err := s.authService.Login(ctx, in.GetEmail(), in.GetPassword(), s.clientInfo(ctx))
 	if err != nil {
 		if errors.Is(err, authservice.ErrInvalidCredentials) {
 			return nil, status.Error(codes.InvalidArgument, "invalid email or password")
 		}
+		if errors.Is(err, authservice.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "email is not verified")
+		}
 
 		return nil, status.Error(codes.Internal, "failed to login")
 	}
 
+	return loginResponse(result), nil
+}
+
+// loginResponse has either token pair or mfa token, if login needs second factor
+func loginResponse(result authservice.LoginResult) *authProto.LoginResponse {
+	if result.MfaToken != "" {
+		return &authProto.LoginResponse{
+			MfaRequired: true,
+			MfaToken:    result.MfaToken,
+		}
+	}
+
 	return &authProto.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+	}
 }
 
 func (s *AuthServer) ValidateUser(
-	_ context.Context,
+	ctx context.Context,
 	in *authProto.ValidateUserRequest,
 ) (*authProto.ValidateUserResponse, error) {
 	response := &authProto.ValidateUserResponse{Valid: false}
@@ -122,15 +299,51 @@ func (s *AuthServer) ValidateUser(
 		return response, nil
 	}
 
-	payload, err := jwt.ParseToken(in.AccessToken)
+	payload, err := s.authService.ParseAccessToken(ctx, in.AccessToken)
+	if err != nil {
+		return response, nil
+	}
+
+	valid, err := s.accessService.HasRole(ctx, payload.Roles, in.RequiredRole)
 	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to validate user")
+	}
+
+	response.Valid = valid
+	return response, nil
+}
+
+// ValidatePermission checks token owner holds required_permission, every permission of all_of
+// and at least one of any_of
+func (s *AuthServer) ValidatePermission(
+	ctx context.Context,
+	in *authProto.ValidatePermissionRequest,
+) (*authProto.ValidatePermissionResponse, error) {
+	if in.RequiredPermission == "" && len(in.AnyOf) == 0 && len(in.AllOf) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "required permissions are missed")
+	}
+
+	response := &authProto.ValidatePermissionResponse{Valid: false}
+	if in.AccessToken == "" {
 		return response, nil
 	}
-	if !(payload.Role == in.RequiredRole) {
+
+	payload, err := s.authService.ParseAccessToken(ctx, in.AccessToken)
+	if err != nil {
 		return response, nil
 	}
 
-	response.Valid = true
+	allOf := in.AllOf
+	if in.RequiredPermission != "" {
+		allOf = append([]string{in.RequiredPermission}, in.AllOf...)
+	}
+
+	valid, err := s.accessService.HasPermissions(ctx, payload.Roles, in.AnyOf, allOf)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to validate permission")
+	}
+
+	response.Valid = valid
 	return response, nil
 }
 
@@ -144,6 +357,10 @@ func (s *AuthServer) Refresh(
 
 	accessToken, refreshToken, err := s.authService.Refresh(ctx, in.RefreshToken)
 	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) || errors.Is(err, authservice.ErrTokenReused) {
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		}
+
 		return nil, status.Error(codes.Internal, "failed to refresh")
 	}
 
@@ -177,11 +394,7 @@ func (s *AuthServer) GetUsers(
 
 	var protoUsers []*authProto.User
 	for _, user := range users {
-		protoUsers = append(protoUsers, &authProto.User{
-			Username: user.Username,
-			Email:    user.Email,
-			RoleId:   user.Role,
-		})
+		protoUsers = append(protoUsers, userToProto(user))
 	}
 
 	return &authProto.GetUsersResponse{
@@ -191,22 +404,22 @@ func (s *AuthServer) GetUsers(
 
 func (s *AuthServer) GetUserByToken(
 	ctx context.Context,
-	in *authProto.GetUserByTokenRequest,
+	_ *authProto.GetUserByTokenRequest,
 ) (*authProto.User, error) {
-	if in.AccessToken == "" {
-		return nil, status.Error(codes.InvalidArgument, "token is required")
+	token, err := accessToken(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	user, err := s.userService.GetUserByToken(ctx, in.AccessToken)
+	user, err := s.userService.GetUserByToken(ctx, token)
 	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
 		return nil, status.Error(codes.Internal, "failed to get user")
 	}
 
-	return &authProto.User{
-		Username: user.Username,
-		Email:    user.Email,
-		RoleId:   user.Role,
-	}, nil
+	return userToProto(user), nil
 }
 
 func (s *AuthServer) DeleteUser(
@@ -222,8 +435,205 @@ func (s *AuthServer) DeleteUser(
 		if errors.Is(err, storage.ErrUserNotFound) {
 			return nil, status.Error(codes.NotFound, "user not found")
 		}
+		if errors.Is(err, storage.ErrLastAdmin) {
+			return nil, status.Error(codes.FailedPrecondition, "last admin can not be deleted")
+		}
 		return nil, status.Error(codes.Internal, "failed to delete user")
 	}
 
 	return &emptypb.Empty{}, nil
 }
+
+func (s *AuthServer) Logout(
+	ctx context.Context,
+	in *authProto.LogoutRequest,
+) (*emptypb.Empty, error) {
+	if in.RefreshToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "token missed")
+	}
+
+	err := s.authService.Logout(ctx, in.RefreshToken)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		}
+		return nil, status.Error(codes.Internal, "failed to logout")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) LogoutAll(
+	ctx context.Context,
+	_ *authProto.LogoutAllRequest,
+) (*emptypb.Empty, error) {
+	token, err := accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.authService.LogoutAll(ctx, token)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return nil, status.Error(codes.Internal, "failed to logout")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) RevokeUserSessions(
+	ctx context.Context,
+	in *authProto.RevokeUserSessionsRequest,
+) (*emptypb.Empty, error) {
+	if in.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+
+	err := s.authService.RevokeUserSessions(ctx, in.Username)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to revoke sessions")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) ListSessions(
+	ctx context.Context,
+	_ *authProto.ListSessionsRequest,
+) (*authProto.ListSessionsResponse, error) {
+	token, err := accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.userService.ListSessions(ctx, token)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return nil, status.Error(codes.Internal, "failed to list sessions")
+	}
+
+	protoSessions := make([]*authProto.Session, 0, len(sessions))
+	for _, session := range sessions {
+		protoSession := &authProto.Session{
+			Id:        session.ID,
+			UserAgent: session.UserAgent,
+			IpAddress: session.IPAddress,
+			CreatedAt: timestamppb.New(session.CreatedAt),
+			Current:   session.Current,
+		}
+		if session.LastRefreshedAt != nil {
+			protoSession.LastRefreshedAt = timestamppb.New(*session.LastRefreshedAt)
+		}
+		protoSessions = append(protoSessions, protoSession)
+	}
+
+	return &authProto.ListSessionsResponse{Sessions: protoSessions}, nil
+}
+
+func (s *AuthServer) RevokeSession(
+	ctx context.Context,
+	in *authProto.RevokeSessionRequest,
+) (*emptypb.Empty, error) {
+	token, err := accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if in.SessionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "session id is required")
+	}
+
+	err = s.userService.RevokeSession(ctx, token, in.SessionId)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return nil, status.Error(codes.NotFound, "session not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to revoke session")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) Introspect(
+	ctx context.Context,
+	in *authProto.IntrospectRequest,
+) (*authProto.IntrospectResponse, error) {
+	if in.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	result, err := s.authService.Introspect(ctx, in.Token, in.TokenTypeHint)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to introspect token")
+	}
+
+	return &authProto.IntrospectResponse{
+		Active:    result.Active,
+		Sub:       result.Subject,
+		Email:     result.Email,
+		Exp:       result.ExpiresAt,
+		Iat:       result.IssuedAt,
+		Scope:     result.Scope,
+		ClientId:  result.ClientID,
+		TokenType: result.TokenType,
+		Role:      result.Role,
+		Roles:     result.Roles,
+	}, nil
+}
+
+func (s *AuthServer) GetJwks(
+	_ context.Context,
+	_ *authProto.GetJwksRequest,
+) (*authProto.GetJwksResponse, error) {
+	jwks := jwt.PublicJWKS()
+
+	protoKeys := make([]*authProto.Jwk, 0, len(jwks.Keys))
+	for _, key := range jwks.Keys {
+		protoKeys = append(protoKeys, &authProto.Jwk{
+			Kty: key.Kty,
+			Kid: key.Kid,
+			Use: key.Use,
+			Alg: key.Alg,
+			N:   key.N,
+			E:   key.E,
+			Crv: key.Crv,
+			X:   key.X,
+			Y:   key.Y,
+		})
+	}
+
+	return &authProto.GetJwksResponse{Keys: protoKeys}, nil
+}
+
+// userToProto fills deprecated role_id with the first of user roles
+func userToProto(user models.User) *authProto.User {
+	protoUser := &authProto.User{
+		Username: user.Username,
+		Email:    user.Email,
+		RoleIds:  user.Roles,
+	}
+	if len(user.Roles) > 0 {
+		protoUser.RoleId = user.Roles[0]
+	}
+
+	return protoUser
+}
+
+// accessToken returns token of principal set by auth interceptor
+func accessToken(ctx context.Context) (string, error) {
+	principal, ok := authinterceptor.PrincipalFromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "token is required")
+	}
+
+	return principal.Token, nil
+}
@@ -0,0 +1,45 @@
+package authserver
+
+import (
+	verificationservice "auth-service/internal/services/verification"
+	"context"
+	"errors"
+	authProto "github.com/SmartAPIForge/protos/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// SendVerificationEmail responds the same way whether email is registered or not
+func (s *AuthServer) SendVerificationEmail(
+	ctx context.Context,
+	in *authProto.SendVerificationEmailRequest,
+) (*emptypb.Empty, error) {
+	if in.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	if err := s.verificationService.SendVerificationEmail(ctx, in.Email); err != nil {
+		return nil, status.Error(codes.Internal, "failed to send verification email")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) VerifyEmail(
+	ctx context.Context,
+	in *authProto.VerifyEmailRequest,
+) (*emptypb.Empty, error) {
+	if in.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	if err := s.verificationService.VerifyEmail(ctx, in.Token); err != nil {
+		if errors.Is(err, verificationservice.ErrInvalidToken) {
+			return nil, status.Error(codes.InvalidArgument, "invalid or expired verification token")
+		}
+		return nil, status.Error(codes.Internal, "failed to verify email")
+	}
+
+	return &emptypb.Empty{}, nil
+}
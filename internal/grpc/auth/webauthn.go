@@ -0,0 +1,118 @@
+package authserver
+
+import (
+	authservice "auth-service/internal/services/auth"
+	"context"
+	"errors"
+	authProto "github.com/SmartAPIForge/protos/gen/go/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"unicode/utf8"
+)
+
+const maxCredentialNameLength = 64
+
+// BeginWebauthnRegistration responds with options_json for navigator.credentials.create
+func (s *AuthServer) BeginWebauthnRegistration(
+	ctx context.Context,
+	in *authProto.BeginWebauthnRegistrationRequest,
+) (*authProto.BeginWebauthnRegistrationResponse, error) {
+	token, err := accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := s.authService.BeginWebauthnRegistration(ctx, token)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return nil, status.Error(codes.Internal, "failed to begin webauthn registration")
+	}
+
+	return &authProto.BeginWebauthnRegistrationResponse{OptionsJson: options}, nil
+}
+
+// FinishWebauthnRegistration accepts JSON of created PublicKeyCredential, name helps user tell passkeys apart
+func (s *AuthServer) FinishWebauthnRegistration(
+	ctx context.Context,
+	in *authProto.FinishWebauthnRegistrationRequest,
+) (*authProto.FinishWebauthnRegistrationResponse, error) {
+	token, err := accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if in.Response == "" {
+		return nil, status.Error(codes.InvalidArgument, "response is required")
+	}
+	if utf8.RuneCountInString(in.Name) > maxCredentialNameLength {
+		return nil, status.Errorf(codes.InvalidArgument, "name must be at most %d characters", maxCredentialNameLength)
+	}
+
+	credentialID, err := s.authService.FinishWebauthnRegistration(ctx, token, in.Response, in.Name)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if errors.Is(err, authservice.ErrInvalidWebauthn) {
+			return nil, status.Error(codes.InvalidArgument, "invalid webauthn response")
+		}
+		if errors.Is(err, authservice.ErrWebauthnCredentialExists) {
+			return nil, status.Error(codes.AlreadyExists, "credential is already registered")
+		}
+		return nil, status.Error(codes.Internal, "failed to finish webauthn registration")
+	}
+
+	return &authProto.FinishWebauthnRegistrationResponse{CredentialId: credentialID}, nil
+}
+
+// BeginWebauthnLogin starts passwordless login, or second factor step of Login if mfa_token is set
+func (s *AuthServer) BeginWebauthnLogin(
+	ctx context.Context,
+	in *authProto.BeginWebauthnLoginRequest,
+) (*authProto.BeginWebauthnLoginResponse, error) {
+	options, err := s.authService.BeginWebauthnLogin(ctx, in.MfaToken)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidMfaToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired mfa token")
+		}
+		if errors.Is(err, authservice.ErrWebauthnNotRegistered) {
+			return nil, status.Error(codes.FailedPrecondition, "no passkeys registered")
+		}
+		return nil, status.Error(codes.Internal, "failed to begin webauthn login")
+	}
+
+	return &authProto.BeginWebauthnLoginResponse{OptionsJson: options}, nil
+}
+
+// FinishWebauthnLogin accepts JSON of PublicKeyCredential returned by navigator.credentials.get
+func (s *AuthServer) FinishWebauthnLogin(
+	ctx context.Context,
+	in *authProto.FinishWebauthnLoginRequest,
+) (*authProto.LoginResponse, error) {
+	if in.Response == "" {
+		return nil, status.Error(codes.InvalidArgument, "response is required")
+	}
+
+	accessToken, refreshToken, err := s.authService.FinishWebauthnLogin(ctx, in.Response, in.MfaToken, s.clientInfo(ctx))
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidMfaToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid or expired mfa token")
+		}
+		if errors.Is(err, authservice.ErrInvalidWebauthn) {
+			return nil, status.Error(codes.Unauthenticated, "invalid webauthn response")
+		}
+		if errors.Is(err, authservice.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "email is not verified")
+		}
+		if errors.Is(err, authservice.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "too many failed attempts, try again later")
+		}
+		return nil, status.Error(codes.Internal, "failed to finish webauthn login")
+	}
+
+	return &authProto.LoginResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
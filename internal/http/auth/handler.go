@@ -0,0 +1,186 @@
+package authhttp
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/clientip"
+	"auth-service/internal/lib/jwt"
+	"auth-service/internal/lib/sl"
+	authservice "auth-service/internal/services/auth"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type AuthService interface {
+	Introspect(
+		ctx context.Context,
+		token string,
+		tokenTypeHint string,
+	) (models.TokenIntrospection, error)
+	ValidateAuthorizationRequest(
+		ctx context.Context,
+		req models.AuthorizationRequest,
+	) (models.OAuthClient, []string, error)
+	GetAuthorizationPrompt(
+		ctx context.Context,
+		accessToken string,
+		req models.AuthorizationRequest,
+	) (models.AuthorizationPrompt, error)
+	Authorize(
+		ctx context.Context,
+		accessToken string,
+		req models.AuthorizationRequest,
+		approved bool,
+	) (string, error)
+	ExchangeAuthorizationCode(
+		ctx context.Context,
+		clientID string,
+		clientSecret string,
+		code string,
+		redirectURI string,
+		codeVerifier string,
+		clientInfo models.ClientInfo,
+	) (authservice.OAuthTokens, error)
+	RefreshOAuthToken(
+		ctx context.Context,
+		clientID string,
+		clientSecret string,
+		refreshToken string,
+		scope string,
+	) (authservice.OAuthTokens, error)
+}
+
+type UserService interface {
+	GetUserByToken(
+		ctx context.Context,
+		accessToken string,
+	) (models.User, error)
+}
+
+type handler struct {
+	log                  *slog.Logger
+	authService          AuthService
+	userService          UserService
+	introspectionClients map[string]string
+	// trustedProxies may report client address in X-Forwarded-For and X-Real-IP
+	trustedProxies clientip.Proxies
+	// oauthLoginURL is frontend page logging user in and asking for consent
+	oauthLoginURL string
+}
+
+func NewHandler(
+	log *slog.Logger,
+	authService AuthService,
+	userService UserService,
+	introspectionClients map[string]string,
+	trustedProxies clientip.Proxies,
+	oauthLoginURL string,
+) http.Handler {
+	h := &handler{
+		log:                  log,
+		authService:          authService,
+		userService:          userService,
+		introspectionClients: introspectionClients,
+		trustedProxies:       trustedProxies,
+		oauthLoginURL:        oauthLoginURL,
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /.well-known/jwks.json", h.jwks)
+	mux.HandleFunc("GET /.well-known/openid-configuration", h.openIDConfiguration)
+	if len(introspectionClients) > 0 {
+		mux.HandleFunc("POST /introspect", h.introspect)
+	} else {
+		log.Warn("INTROSPECTION_CLIENTS is empty, /introspect is disabled")
+	}
+	mux.HandleFunc("GET /authorize", h.authorize)
+	mux.HandleFunc("GET /authorize/consent", h.consentPrompt)
+	mux.HandleFunc("POST /authorize/consent", h.consent)
+	mux.HandleFunc("POST /token", h.token)
+	mux.HandleFunc("GET /userinfo", h.userInfo)
+	mux.HandleFunc("POST /userinfo", h.userInfo)
+
+	return mux
+}
+
+func (h *handler) jwks(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, jwt.PublicJWKS())
+}
+
+// introspectionResponse is RFC 7662 section 2.2 response
+type introspectionResponse struct {
+	Active    bool    `json:"active"`
+	Sub       string  `json:"sub,omitempty"`
+	Email     string  `json:"email,omitempty"`
+	Exp       int64   `json:"exp,omitempty"`
+	Iat       int64   `json:"iat,omitempty"`
+	Scope     string  `json:"scope,omitempty"`
+	ClientID  string  `json:"client_id,omitempty"`
+	TokenType string  `json:"token_type,omitempty"`
+	Role      int64   `json:"role,omitempty"`
+	Roles     []int64 `json:"roles,omitempty"`
+}
+
+func (h *handler) introspect(w http.ResponseWriter, r *http.Request) {
+	const op = "authhttp.introspect"
+
+	log := h.log.With(slog.String("op", op))
+
+	if !h.authorizeIntrospection(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspect"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	result, err := h.authService.Introspect(r.Context(), token, r.PostFormValue("token_type_hint"))
+	if err != nil {
+		log.Error("failed to introspect token", sl.Err(err))
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, introspectionResponse{
+		Active:    result.Active,
+		Sub:       result.Subject,
+		Email:     result.Email,
+		Exp:       result.ExpiresAt,
+		Iat:       result.IssuedAt,
+		Scope:     result.Scope,
+		ClientID:  result.ClientID,
+		TokenType: result.TokenType,
+		Role:      result.Role,
+		Roles:     result.Roles,
+	})
+}
+
+// authorizeIntrospection checks HTTP basic credentials of the protected resource calling /introspect
+func (h *handler) authorizeIntrospection(r *http.Request) bool {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	expected, exists := h.introspectionClients[clientID]
+	if !exists {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(clientSecret), []byte(expected)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,319 @@
+package authhttp
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/sl"
+	authservice "auth-service/internal/services/auth"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tokenResponse is RFC 6749 section 5.1 response
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// oauthError is RFC 6749 section 5.2 response, RedirectTo is set for consent page to send user back to client
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	RedirectTo  string `json:"redirect_to,omitempty"`
+}
+
+type consentPromptResponse struct {
+	ClientID        string   `json:"client_id"`
+	ClientName      string   `json:"client_name"`
+	Scopes          []string `json:"scopes"`
+	ConsentRequired bool     `json:"consent_required"`
+}
+
+type consentResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// authorize validates request and sends user to login page, which receives the same query.
+// After login the page asks /authorize/consent whether consent is needed and posts the decision there
+func (h *handler) authorize(w http.ResponseWriter, r *http.Request) {
+	const op = "authhttp.authorize"
+
+	log := h.log.With(slog.String("op", op))
+
+	req := authorizationRequest(r.URL.Query())
+
+	if _, _, err := h.authService.ValidateAuthorizationRequest(r.Context(), req); err != nil {
+		if errors.Is(err, authservice.ErrInvalidClient) || errors.Is(err, authservice.ErrInvalidRedirectURI) {
+			writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_request", Description: "unknown client or redirect_uri"})
+			return
+		}
+		code := authorizationErrorCode(err)
+		if code == "server_error" {
+			log.Error("failed to validate authorization request", sl.Err(err))
+		}
+		h.redirectBack(w, r, req, url.Values{"error": {code}})
+		return
+	}
+
+	http.Redirect(w, r, redirectURL(h.oauthLoginURL, r.URL.Query()), http.StatusFound)
+}
+
+// consentPrompt tells login page which client asks for which scopes, request is passed as query
+func (h *handler) consentPrompt(w http.ResponseWriter, r *http.Request) {
+	const op = "authhttp.consentPrompt"
+
+	log := h.log.With(slog.String("op", op))
+
+	req := authorizationRequest(r.URL.Query())
+
+	prompt, err := h.authService.GetAuthorizationPrompt(r.Context(), bearerToken(r), req)
+	if err != nil {
+		h.writeAuthorizationError(w, log, req, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, consentPromptResponse{
+		ClientID:        prompt.ClientID,
+		ClientName:      prompt.ClientName,
+		Scopes:          prompt.Scopes,
+		ConsentRequired: prompt.ConsentRequired,
+	})
+}
+
+// consent records decision=allow|deny of logged in user, request is passed as form.
+// Response contains client redirect URI with either code or error, login page follows it
+func (h *handler) consent(w http.ResponseWriter, r *http.Request) {
+	const op = "authhttp.consent"
+
+	log := h.log.With(slog.String("op", op))
+
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_request"})
+		return
+	}
+	req := authorizationRequest(r.PostForm)
+
+	decision := r.PostForm.Get("decision")
+	if decision != "allow" && decision != "deny" {
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_request", Description: "decision must be allow or deny"})
+		return
+	}
+
+	code, err := h.authService.Authorize(r.Context(), bearerToken(r), req, decision == "allow")
+	if err != nil {
+		if errors.Is(err, authservice.ErrAccessDenied) {
+			writeJSON(w, http.StatusOK, consentResponse{
+				RedirectTo: redirectURL(req.RedirectURI, url.Values{"error": {"access_denied"}, "state": stateValue(req)}),
+			})
+			return
+		}
+		h.writeAuthorizationError(w, log, req, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, consentResponse{
+		RedirectTo: redirectURL(req.RedirectURI, url.Values{"code": {code}, "state": stateValue(req)}),
+	})
+}
+
+// token is RFC 6749 token endpoint supporting authorization_code and refresh_token grants.
+// Confidential clients authenticate with HTTP basic or client_secret form parameter
+func (h *handler) token(w http.ResponseWriter, r *http.Request) {
+	const op = "authhttp.token"
+
+	log := h.log.With(slog.String("op", op))
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, basic := clientCredentials(r)
+	if clientID == "" {
+		writeJSON(w, http.StatusUnauthorized, oauthError{Error: "invalid_client"})
+		return
+	}
+
+	var (
+		tokens authservice.OAuthTokens
+		err    error
+	)
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, codeVerifier := r.PostForm.Get("code"), r.PostForm.Get("code_verifier")
+		if code == "" || codeVerifier == "" {
+			writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_request", Description: "code and code_verifier are required"})
+			return
+		}
+		tokens, err = h.authService.ExchangeAuthorizationCode(
+			r.Context(),
+			clientID,
+			clientSecret,
+			code,
+			r.PostForm.Get("redirect_uri"),
+			codeVerifier,
+			h.clientInfo(r),
+		)
+	case "refresh_token":
+		refreshToken := r.PostForm.Get("refresh_token")
+		if refreshToken == "" {
+			writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_request", Description: "refresh_token is required"})
+			return
+		}
+		tokens, err = h.authService.RefreshOAuthToken(r.Context(), clientID, clientSecret, refreshToken, r.PostForm.Get("scope"))
+	default:
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "unsupported_grant_type"})
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, authservice.ErrInvalidClient):
+			if basic {
+				w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+			}
+			writeJSON(w, http.StatusUnauthorized, oauthError{Error: "invalid_client"})
+		case errors.Is(err, authservice.ErrInvalidGrant):
+			writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_grant"})
+		case errors.Is(err, authservice.ErrInvalidScope):
+			writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_scope"})
+		default:
+			log.Error("failed to issue tokens", sl.Err(err))
+			writeJSON(w, http.StatusInternalServerError, oauthError{Error: "server_error"})
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      tokens.IDToken,
+		Scope:        tokens.Scope,
+	})
+}
+
+// writeAuthorizationError responds to consent page, errors for valid redirect URI carry redirect_to
+func (h *handler) writeAuthorizationError(w http.ResponseWriter, log *slog.Logger, req models.AuthorizationRequest, err error) {
+	switch {
+	case errors.Is(err, authservice.ErrInvalidToken):
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, oauthError{Error: "invalid_token"})
+	case errors.Is(err, authservice.ErrInvalidClient), errors.Is(err, authservice.ErrInvalidRedirectURI):
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_request", Description: "unknown client or redirect_uri"})
+	default:
+		code := authorizationErrorCode(err)
+		status := http.StatusBadRequest
+		if code == "server_error" {
+			log.Error("failed to process authorization request", sl.Err(err))
+			status = http.StatusInternalServerError
+		}
+		writeJSON(w, status, oauthError{
+			Error:      code,
+			RedirectTo: redirectURL(req.RedirectURI, url.Values{"error": {code}, "state": stateValue(req)}),
+		})
+	}
+}
+
+// redirectBack reports error of authorization request to client, redirect URI must be validated before
+func (h *handler) redirectBack(w http.ResponseWriter, r *http.Request, req models.AuthorizationRequest, params url.Values) {
+	params["state"] = stateValue(req)
+	http.Redirect(w, r, redirectURL(req.RedirectURI, params), http.StatusFound)
+}
+
+// authorizationErrorCode maps service error to RFC 6749 section 4.1.2.1 error code
+func authorizationErrorCode(err error) string {
+	switch {
+	case errors.Is(err, authservice.ErrUnsupportedResponseType):
+		return "unsupported_response_type"
+	case errors.Is(err, authservice.ErrInvalidScope):
+		return "invalid_scope"
+	case errors.Is(err, authservice.ErrInvalidOAuthRequest):
+		return "invalid_request"
+	case errors.Is(err, authservice.ErrAccessDenied):
+		return "access_denied"
+	default:
+		return "server_error"
+	}
+}
+
+func authorizationRequest(values url.Values) models.AuthorizationRequest {
+	return models.AuthorizationRequest{
+		ResponseType:        values.Get("response_type"),
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		Scope:               values.Get("scope"),
+		State:               values.Get("state"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+		Nonce:               values.Get("nonce"),
+	}
+}
+
+// redirectURL appends params to URL keeping its own query
+func redirectURL(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+func stateValue(req models.AuthorizationRequest) []string {
+	if req.State == "" {
+		return nil
+	}
+	return []string{req.State}
+}
+
+// clientCredentials reads HTTP basic credentials, which are form encoded per RFC 6749 section 2.3.1,
+// falling back to client_id and client_secret form parameters
+func clientCredentials(r *http.Request) (clientID string, clientSecret string, basic bool) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		id, errID := url.QueryUnescape(id)
+		secret, errSecret := url.QueryUnescape(secret)
+		if errID != nil || errSecret != nil {
+			return "", "", true
+		}
+		return id, secret, true
+	}
+
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), false
+}
+
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+// clientInfo describes device exchanging authorization code, the same way gRPC metadata does
+func (h *handler) clientInfo(r *http.Request) models.ClientInfo {
+	return models.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: h.trustedProxies.Resolve(r.RemoteAddr, r.Header.Values("X-Forwarded-For"), r.Header.Get("X-Real-IP")),
+	}
+}
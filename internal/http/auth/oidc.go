@@ -0,0 +1,124 @@
+package authhttp
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/jwt"
+	"auth-service/internal/lib/sl"
+	authservice "auth-service/internal/services/auth"
+	"auth-service/internal/storage"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// discoveryDocument is OpenID Connect Discovery 1.0 provider metadata
+type discoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint,omitempty"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// userInfoResponse is OpenID Connect standard claims of token owner
+type userInfoResponse struct {
+	Sub               string `json:"sub"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// openIDConfiguration publishes endpoints relative to issuer, so JWT_ISSUER must be public URL of this server
+func (h *handler) openIDConfiguration(w http.ResponseWriter, _ *http.Request) {
+	issuer := jwt.Issuer()
+	base := strings.TrimSuffix(issuer, "/")
+
+	algorithms := []string{}
+	for _, key := range jwt.PublicJWKS().Keys {
+		if !slices.Contains(algorithms, key.Alg) {
+			algorithms = append(algorithms, key.Alg)
+		}
+	}
+
+	// introspection is not served without INTROSPECTION_CLIENTS
+	var introspectionEndpoint string
+	if len(h.introspectionClients) > 0 {
+		introspectionEndpoint = base + "/introspect"
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, discoveryDocument{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             base + "/authorize",
+		TokenEndpoint:                     base + "/token",
+		UserinfoEndpoint:                  base + "/userinfo",
+		JwksURI:                           base + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             introspectionEndpoint,
+		ScopesSupported:                   models.OIDCScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "azp",
+			"preferred_username", "email", "email_verified",
+		},
+	})
+}
+
+// userInfo returns claims of scopes granted to client, access token must have openid scope
+func (h *handler) userInfo(w http.ResponseWriter, r *http.Request) {
+	const op = "authhttp.userInfo"
+
+	log := h.log.With(slog.String("op", op))
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	token := bearerToken(r)
+	claims, err := jwt.ParseToken(token, jwt.TokenTypeAccess)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeJSON(w, http.StatusUnauthorized, oauthError{Error: "invalid_token"})
+		return
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if !slices.Contains(scopes, models.ScopeOpenID) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		writeJSON(w, http.StatusForbidden, oauthError{Error: "insufficient_scope"})
+		return
+	}
+
+	user, err := h.userService.GetUserByToken(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, authservice.ErrInvalidToken) || errors.Is(err, storage.ErrUserNotFound) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeJSON(w, http.StatusUnauthorized, oauthError{Error: "invalid_token"})
+			return
+		}
+		log.Error("failed to get user", sl.Err(err))
+		writeJSON(w, http.StatusInternalServerError, oauthError{Error: "server_error"})
+		return
+	}
+
+	info := models.NewUserInfo(user, scopes)
+	writeJSON(w, http.StatusOK, userInfoResponse{
+		Sub:               info.Subject,
+		PreferredUsername: info.PreferredUsername,
+		Email:             info.Email,
+		EmailVerified:     info.EmailVerified,
+	})
+}
@@ -0,0 +1,169 @@
+package authinterceptor
+
+import (
+	"auth-service/internal/lib/jwt"
+	"auth-service/internal/lib/sl"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"strings"
+)
+
+// Access is a level of protection of RPC
+type Access int
+
+const (
+	// Public methods are callable without token
+	Public Access = iota
+	// Authenticated methods require valid access token, holding Permission if the rule sets it
+	Authenticated
+	// SelfOr methods require token of the user the request is about or holding Permission
+	SelfOr
+)
+
+// Rule protects single method. Permission is checked against roles of the token, admin holds every permission.
+// Owner returns username the request is about, it is required by SelfOr
+type Rule struct {
+	Access     Access
+	Permission string
+	Owner      func(req any) string
+}
+
+// Policy maps full method name (/package.Service/Method) to its rule. Methods missing in policy are denied
+type Policy map[string]Rule
+
+type TokenParser interface {
+	ParseAccessToken(
+		ctx context.Context,
+		accessToken string,
+	) (*jwt.Claims, error)
+}
+
+type PermissionChecker interface {
+	HasPermissions(
+		ctx context.Context,
+		roleIDs []int64,
+		anyOf []string,
+		allOf []string,
+	) (bool, error)
+}
+
+// Principal is the caller resolved from access token
+type Principal struct {
+	Token  string
+	Claims *jwt.Claims
+}
+
+type principalKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// tokenRequest is implemented by requests carrying access token in body
+type tokenRequest interface {
+	GetAccessToken() string
+}
+
+// UnaryServerInterceptor enforces policy. Token is taken from "authorization: Bearer <token>" metadata,
+// falling back to access_token field of the request. Principal of public methods is set when token is valid.
+// Tokens issued to OAuth clients carry scope, they are for resource servers and can not call the service
+func UnaryServerInterceptor(
+	log *slog.Logger,
+	policy Policy,
+	tokenParser TokenParser,
+	permissionChecker PermissionChecker,
+) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		const op = "authinterceptor.UnaryServerInterceptor"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("method", info.FullMethod),
+		)
+
+		rule, ok := policy[info.FullMethod]
+		if !ok {
+			log.Warn("method is not covered by access policy")
+			return nil, status.Error(codes.PermissionDenied, "access denied")
+		}
+
+		token := bearerToken(ctx)
+		if token == "" {
+			if r, ok := req.(tokenRequest); ok {
+				token = r.GetAccessToken()
+			}
+		}
+
+		if rule.Access == Public {
+			if token != "" {
+				if claims, err := tokenParser.ParseAccessToken(ctx, token); err == nil && claims.Scope == "" {
+					ctx = ContextWithPrincipal(ctx, Principal{Token: token, Claims: claims})
+				}
+			}
+			return handler(ctx, req)
+		}
+
+		if token == "" {
+			return nil, status.Error(codes.Unauthenticated, "token is required")
+		}
+
+		claims, err := tokenParser.ParseAccessToken(ctx, token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if claims.Scope != "" {
+			return nil, status.Error(codes.PermissionDenied, "token is issued to oauth client")
+		}
+		ctx = ContextWithPrincipal(ctx, Principal{Token: token, Claims: claims})
+
+		if rule.Access == SelfOr && rule.Owner != nil && claims.Username != "" && rule.Owner(req) == claims.Username {
+			return handler(ctx, req)
+		}
+		if rule.Permission == "" {
+			if rule.Access == SelfOr {
+				return nil, status.Error(codes.PermissionDenied, "access denied")
+			}
+			return handler(ctx, req)
+		}
+
+		granted, err := permissionChecker.HasPermissions(ctx, claims.Roles, nil, []string{rule.Permission})
+		if err != nil {
+			log.Error("failed to check permission", sl.Err(err))
+			return nil, status.Error(codes.Internal, "failed to authorize")
+		}
+		if !granted {
+			return nil, status.Errorf(codes.PermissionDenied, "%s permission required", rule.Permission)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func bearerToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
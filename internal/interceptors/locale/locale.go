@@ -0,0 +1,57 @@
+package localeinterceptor
+
+import (
+	"auth-service/internal/mailer"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strconv"
+	"strings"
+)
+
+// UnaryServerInterceptor puts the most preferred language of accept-language metadata into context,
+// emails sent while handling the request are rendered in it
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if locale := preferredLocale(ctx); locale != "" {
+			ctx = mailer.ContextWithLocale(ctx, locale)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// preferredLocale picks tag with the highest weight from "ru-RU,ru;q=0.9,en;q=0.8"
+func preferredLocale(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("accept-language")
+	if len(values) == 0 {
+		return ""
+	}
+
+	best, bestWeight := "", -1.0
+	for _, item := range strings.Split(values[0], ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		weight := 1.0
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			var err error
+			if weight, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if weight > bestWeight {
+			best, bestWeight = tag, weight
+		}
+	}
+
+	return best
+}
@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"auth-service/internal/lib/sl"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"log/slog"
 )
@@ -29,15 +30,32 @@ func NewKafkaProducer(
 }
 
 func (kp *KafkaProducer) ProduceNewUser(key string, native map[string]interface{}) error {
-	kp.log.Info("Sending NewUser message: ", key, native)
+	return kp.send("NewUser", key, native)
+}
+
+func (kp *KafkaProducer) ProducePasswordChanged(key string, native map[string]interface{}) error {
+	return kp.send("PasswordChanged", key, native)
+}
+
+func (kp *KafkaProducer) ProduceRecoveryCodeUsed(key string, native map[string]interface{}) error {
+	return kp.send("RecoveryCodeUsed", key, native)
+}
+
+func (kp *KafkaProducer) send(topic string, key string, native map[string]interface{}) error {
+	log := kp.log.With(
+		slog.String("topic", topic),
+		slog.String("key", key),
+	)
+
+	log.Info("sending message", slog.Any("value", native))
 
-	err := kp.produce("NewUser", key, native)
+	err := kp.produce(topic, key, native)
 	if err != nil {
-		kp.log.Error("Error sending NewUser message: ", key, err)
+		log.Error("error sending message", sl.Err(err))
 		return err
 	}
 
-	kp.log.Error("Successfully sent NewUser message: ", key)
+	log.Info("successfully sent message")
 	return nil
 }
 
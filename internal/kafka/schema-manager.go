@@ -9,7 +9,9 @@ import (
 )
 
 var schemasForThisService = map[string]*goavro.Codec{
-	"NewUser": nil,
+	"NewUser":          nil,
+	"PasswordChanged":  nil,
+	"RecoveryCodeUsed": nil,
 }
 
 type SchemaManager struct {
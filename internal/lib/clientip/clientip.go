@@ -0,0 +1,88 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Proxies are networks of reverse proxies trusted to report client address in forwarding headers
+type Proxies []netip.Prefix
+
+// ParseProxies accepts single addresses and CIDR networks
+func ParseProxies(values []string) (Proxies, error) {
+	proxies := make(Proxies, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+
+	return proxies, nil
+}
+
+// Resolve returns address of the client request came from through peer. Forwarded addresses
+// are honoured only when peer is a trusted proxy: x-forwarded-for is walked from the right
+// skipping trusted proxies, x-real-ip is used when there is no x-forwarded-for
+func (p Proxies) Resolve(peer string, forwardedFor []string, realIP string) string {
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !p.trusted(peer) {
+		return peer
+	}
+
+	var hops []string
+	for _, value := range forwardedFor {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// garbage can only come from the client itself, trusted proxies append valid addresses
+			break
+		}
+		if !p.trusted(hop) || i == 0 {
+			return hop
+		}
+	}
+
+	if realIP = strings.TrimSpace(realIP); len(hops) == 0 && realIP != "" {
+		if _, err := netip.ParseAddr(realIP); err == nil {
+			return realIP
+		}
+	}
+
+	return peer
+}
+
+func (p Proxies) trusted(address string) bool {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,165 @@
+package clientip
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", "fd00::1"})
+	if err != nil {
+		t.Fatalf("ParseProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		proxies      Proxies
+		peer         string
+		forwardedFor []string
+		realIP       string
+		want         string
+	}{
+		{
+			name: "no proxies configured",
+			peer: "10.0.0.1:443", forwardedFor: []string{"1.1.1.1"}, realIP: "2.2.2.2",
+			want: "10.0.0.1",
+		},
+		{
+			name:    "untrusted peer forging headers",
+			proxies: proxies,
+			peer:    "203.0.113.7:51000", forwardedFor: []string{"1.1.1.1"}, realIP: "2.2.2.2",
+			want: "203.0.113.7",
+		},
+		{
+			name:    "trusted peer without headers",
+			proxies: proxies,
+			peer:    "10.0.0.1:443",
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "single trusted proxy",
+			proxies: proxies,
+			peer:    "10.0.0.1:443", forwardedFor: []string{"1.1.1.1"},
+			want: "1.1.1.1",
+		},
+		{
+			name:    "client prepending forged hop",
+			proxies: proxies,
+			peer:    "10.0.0.1:443", forwardedFor: []string{"9.9.9.9, 1.1.1.1"},
+			want: "1.1.1.1",
+		},
+		{
+			name:    "chain of trusted proxies",
+			proxies: proxies,
+			peer:    "10.0.0.1:443", forwardedFor: []string{"9.9.9.9, 1.1.1.1, 10.0.0.3", "10.0.0.2"},
+			want: "1.1.1.1",
+		},
+		{
+			name:    "chain of trusted proxies only",
+			proxies: proxies,
+			peer:    "10.0.0.1:443", forwardedFor: []string{"10.0.0.3, 10.0.0.2"},
+			want: "10.0.0.3",
+		},
+		{
+			name:    "ipv4-mapped ipv6 peer",
+			proxies: proxies,
+			peer:    "[::ffff:10.0.0.1]:443", forwardedFor: []string{"1.1.1.1"},
+			want: "1.1.1.1",
+		},
+		{
+			name:    "untrusted ipv4-mapped ipv6 peer",
+			proxies: proxies,
+			peer:    "[::ffff:203.0.113.7]:443", forwardedFor: []string{"1.1.1.1"},
+			want: "::ffff:203.0.113.7",
+		},
+		{
+			name:    "ipv6 trusted proxy",
+			proxies: proxies,
+			peer:    "[fd00::1]:443", forwardedFor: []string{"2001:db8::5"},
+			want: "2001:db8::5",
+		},
+		{
+			name:    "garbage left of client is ignored",
+			proxies: proxies,
+			peer:    "10.0.0.1:443", forwardedFor: []string{"<script>, 1.1.1.1"},
+			want: "1.1.1.1",
+		},
+		{
+			name:    "garbage cuts off walk",
+			proxies: proxies,
+			peer:    "10.0.0.1:443", forwardedFor: []string{"1.1.1.1, unknown, 10.0.0.2"},
+			want: "10.0.0.1",
+		},
+		{
+			name:    "real ip fallback",
+			proxies: proxies,
+			peer:    "10.0.0.1:443", realIP: " 1.1.1.1 ",
+			want: "1.1.1.1",
+		},
+		{
+			name:    "forwarded for takes precedence over real ip",
+			proxies: proxies,
+			peer:    "10.0.0.1:443", forwardedFor: []string{"1.1.1.1"}, realIP: "2.2.2.2",
+			want: "1.1.1.1",
+		},
+		{
+			name:    "malformed real ip",
+			proxies: proxies,
+			peer:    "10.0.0.1:443", realIP: "1.1.1",
+			want: "10.0.0.1",
+		},
+		{
+			name:    "peer without port",
+			proxies: proxies,
+			peer:    "10.0.0.1", forwardedFor: []string{"1.1.1.1"},
+			want: "1.1.1.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.proxies.Resolve(tt.peer, tt.forwardedFor, tt.realIP); got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    Proxies
+		wantErr bool
+	}{
+		{
+			name:   "addresses and networks",
+			values: []string{" 10.0.0.1 ", "", "192.168.1.7/24", "::ffff:172.16.0.1", "fd00::/8"},
+			want: Proxies{
+				netip.MustParsePrefix("10.0.0.1/32"),
+				netip.MustParsePrefix("192.168.1.0/24"),
+				netip.MustParsePrefix("172.16.0.1/32"),
+				netip.MustParsePrefix("fd00::/8"),
+			},
+		},
+		{name: "invalid address", values: []string{"10.0.0"}, wantErr: true},
+		{name: "invalid network", values: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProxies(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseProxies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseProxies() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ParseProxies()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
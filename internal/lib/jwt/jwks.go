@@ -0,0 +1,120 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+)
+
+// JWK is a public key in RFC 7517 format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS returns public parts of keys tokens are verified with
+func PublicJWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	keyRing := ring.Load()
+	if keyRing == nil {
+		return jwks
+	}
+
+	for _, key := range keyRing.Keys() {
+		jwk, err := NewJWK(key.PublicKey())
+		if err != nil {
+			continue
+		}
+		jwk.Kid = key.ID
+		jwk.Use = "sig"
+		jwk.Alg = key.Algorithm
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+// NewJWK converts public key to JWK containing only required members
+func NewJWK(publicKey crypto.PublicKey) (JWK, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   encode(key.N.Bytes()),
+			E:   encode(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		ecdhKey, err := key.ECDH()
+		if err != nil {
+			return JWK{}, err
+		}
+		// uncompressed point: 0x04 || X || Y
+		point := ecdhKey.Bytes()
+		size := (len(point) - 1) / 2
+		return JWK{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   encode(point[1 : 1+size]),
+			Y:   encode(point[1+size:]),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   encode(key),
+		}, nil
+	default:
+		return JWK{}, ErrUnsupportedAlgorithm
+	}
+}
+
+// Thumbprint computes RFC 7638 JWK thumbprint of public key
+func Thumbprint(publicKey crypto.PublicKey) (string, error) {
+	jwk, err := NewJWK(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	// members must be in lexicographic order, as json.Marshal does for maps
+	members := map[string]string{"kty": jwk.Kty}
+	switch jwk.Kty {
+	case "RSA":
+		members["n"] = jwk.N
+		members["e"] = jwk.E
+	case "EC":
+		members["crv"] = jwk.Crv
+		members["x"] = jwk.X
+		members["y"] = jwk.Y
+	case "OKP":
+		members["crv"] = jwk.Crv
+		members["x"] = jwk.X
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return encode(sum[:]), nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
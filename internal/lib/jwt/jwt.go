@@ -2,64 +2,251 @@ package jwt
 
 import (
 	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/secret"
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
+	"slices"
+	"strconv"
 	"time"
 )
 
-type TokenPayload struct {
-	Type  string
-	Uid   int64
-	Email string
-	Role  int64
-	Exp   int64
+var (
+	ErrNoKeyRing     = errors.New("key ring is not set")
+	ErrUnknownKey    = errors.New("unknown key id")
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrSigningMethod = errors.New("unexpected signing method")
+	ErrTokenType     = errors.New("unexpected token type")
+	ErrAudience      = errors.New("token is not issued for known audience")
+)
+
+const (
+	TokenTypeAccess            = "access"
+	TokenTypeRefresh           = "refresh"
+	TokenTypeEmailVerification = "email_verification"
+	TokenTypeID                = "id"
+)
+
+var supportedAlgorithms = []string{AlgRS256, AlgES256, AlgEdDSA}
+
+// Options of issued tokens and their validation
+type Options struct {
+	Issuer string
+	// DefaultAudience is given to tokens of direct logins, which are not bound to a client
+	DefaultAudience string
+	// OAuthAudience is given to tokens of OAuth clients missing in ClientAudiences,
+	// first-party APIs must not accept it
+	OAuthAudience string
+	// ClientAudiences maps OAuth client id to audiences of tokens issued for it
+	ClientAudiences map[string][]string
+	// Leeway is allowed clock skew for exp, nbf and iat checks
+	Leeway time.Duration
+}
+
+// options are set once on startup via Configure
+var options = Options{
+	Issuer:          "auth-service",
+	DefaultAudience: "smartapiforge",
+	OAuthAudience:   "smartapiforge-oauth",
+}
+
+func Configure(opts Options) {
+	options = opts
 }
 
+// Claims of tokens issued by the service. Subject duplicates uid as string per RFC 7519,
+// email_verified lets consumers restrict tokens of users who have not confirmed email yet
+type Claims struct {
+	jwt.RegisteredClaims
+	Type          string  `json:"type"`
+	SessionID     string  `json:"sid,omitempty"`
+	ClientID      string  `json:"azp,omitempty"`
+	Uid           int64   `json:"uid"`
+	Username      string  `json:"username,omitempty"`
+	Email         string  `json:"email"`
+	EmailVerified bool    `json:"email_verified"`
+	Roles         []int64 `json:"roles"`
+	// Role is the first of Roles, kept for consumers reading single role claim
+	Role int64 `json:"role"`
+	// Scope is space separated scopes user granted to OAuth client, empty for tokens of direct login
+	Scope string `json:"scope,omitempty"`
+}
+
+// NewToken signs token of tokenType for user within the session, audience depends on clientID
 func NewToken(
 	user models.User,
 	duration time.Duration,
 	tokenType string,
-) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+	sessionID string,
+	clientID string,
+	scope string,
+) (string, *Claims, error) {
+	keyRing := ring.Load()
+	if keyRing == nil {
+		return "", nil, ErrNoKeyRing
+	}
+	signingKey := keyRing.Active()
+
+	now := time.Now()
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        secret.Generate(16),
+			Issuer:    options.Issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  audience(clientID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		},
+		Type:          tokenType,
+		SessionID:     sessionID,
+		ClientID:      clientID,
+		Uid:           user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Roles:         user.Roles,
+		Scope:         scope,
+	}
+	if len(user.Roles) > 0 {
+		claims.Role = user.Roles[0]
+	}
 
-	claims := token.Claims.(jwt.MapClaims)
-	claims["type"] = tokenType
-	claims["uid"] = user.ID
-	claims["email"] = user.Email
-	claims["role"] = user.Role
-	claims["exp"] = time.Now().Add(duration).Unix()
+	token := jwt.NewWithClaims(signingKey.method(), claims)
+	token.Header["kid"] = signingKey.ID
 
-	tokenString, err := token.SignedString([]byte("do_not_forget_to_hide_please"))
+	tokenString, err := token.SignedString(signingKey.PrivateKey)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
-	return tokenString, nil
+	return tokenString, claims, nil
 }
 
-func ParseToken(tokenString string) (*TokenPayload, error) {
-	var payload TokenPayload
+// IDClaims of OpenID Connect ID token, it is issued for client, so the audience is client_id
+type IDClaims struct {
+	jwt.RegisteredClaims
+	Type              string `json:"type"`
+	ClientID          string `json:"azp"`
+	Nonce             string `json:"nonce,omitempty"`
+	AuthTime          int64  `json:"auth_time,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// NewIDToken signs ID token for client carrying user claims of granted scopes
+func NewIDToken(
+	info models.UserInfo,
+	duration time.Duration,
+	clientID string,
+	nonce string,
+	authTime time.Time,
+) (string, error) {
+	keyRing := ring.Load()
+	if keyRing == nil {
+		return "", ErrNoKeyRing
+	}
+	signingKey := keyRing.Active()
+
+	now := time.Now()
+	claims := &IDClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        secret.Generate(16),
+			Issuer:    options.Issuer,
+			Subject:   info.Subject,
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		},
+		Type:              TokenTypeID,
+		ClientID:          clientID,
+		Nonce:             nonce,
+		PreferredUsername: info.PreferredUsername,
+		Email:             info.Email,
+		EmailVerified:     info.EmailVerified,
+	}
+	if !authTime.IsZero() {
+		claims.AuthTime = authTime.Unix()
+	}
+
+	token := jwt.NewWithClaims(signingKey.method(), claims)
+	token.Header["kid"] = signingKey.ID
+
+	return token.SignedString(signingKey.PrivateKey)
+}
+
+// Issuer is iss claim of issued tokens
+func Issuer() string {
+	return options.Issuer
+}
+
+// ParseToken verifies token signature, registered claims and checks it is of expected tokenType,
+// so access token can not be used as refresh one and vice versa
+func ParseToken(tokenString string, tokenType string) (*Claims, error) {
+	keyRing := ring.Load()
+	if keyRing == nil {
+		return nil, ErrNoKeyRing
+	}
+
+	var claims Claims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keyRing.Lookup(kid)
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, ErrSigningMethod
 		}
-		return []byte("do_not_forget_to_hide_please"), nil
-	})
+		return key.PublicKey(), nil
+	},
+		jwt.WithValidMethods(supportedAlgorithms),
+		jwt.WithIssuer(options.Issuer),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(options.Leeway),
+	)
 	if err != nil {
 		return nil, err
 	}
+	if !token.Valid || claims.ExpiresAt == nil || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if !slices.ContainsFunc(claims.Audience, knownAudience) {
+		return nil, ErrAudience
+	}
+	if claims.Type != tokenType {
+		return nil, ErrTokenType
+	}
+	// tokens issued before multiple roles carry only role claim
+	if claims.Roles == nil && claims.Role != 0 {
+		claims.Roles = []int64{claims.Role}
+	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		payload = TokenPayload{
-			Type:  claims["type"].(string),
-			Uid:   int64(claims["uid"].(float64)),
-			Email: claims["email"].(string),
-			Role:  int64(claims["role"].(float64)),
-			Exp:   int64(claims["exp"].(float64)),
+	return &claims, nil
+}
+
+// audience of tokens issued for client, clientID is set only for authenticated OAuth clients
+func audience(clientID string) jwt.ClaimStrings {
+	if clientID == "" {
+		return jwt.ClaimStrings{options.DefaultAudience}
+	}
+	if audiences, ok := options.ClientAudiences[clientID]; ok {
+		return audiences
+	}
+
+	return jwt.ClaimStrings{options.OAuthAudience}
+}
+
+func knownAudience(aud string) bool {
+	if aud == options.DefaultAudience || aud == options.OAuthAudience {
+		return true
+	}
+	for _, audiences := range options.ClientAudiences {
+		if slices.Contains(audiences, aud) {
+			return true
 		}
-		return &payload, nil
 	}
 
-	return nil, errors.New("invalid token")
+	return false
 }
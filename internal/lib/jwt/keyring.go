@@ -0,0 +1,79 @@
+package jwt
+
+import (
+	"auth-service/internal/domain/models"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNoActiveKey = errors.New("no active signing key")
+
+// KeyRing holds the active signing key and all keys tokens may still be verified with
+type KeyRing struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// ring is replaced atomically on every keys reload
+var ring atomic.Pointer[KeyRing]
+
+func SetKeyRing(r *KeyRing) {
+	ring.Store(r)
+}
+
+// NewKeyRing builds ring from keys, dropping ones whose verification window is over
+func NewKeyRing(keys []*SigningKey) (*KeyRing, error) {
+	now := time.Now()
+	r := &KeyRing{keys: make(map[string]*SigningKey, len(keys))}
+
+	for _, key := range keys {
+		if !key.canVerify(now) {
+			continue
+		}
+		if key.Status == models.KeyStatusActive {
+			if r.active != nil {
+				return nil, errors.New("more than one active signing key")
+			}
+			r.active = key
+		}
+		r.keys[key.ID] = key
+	}
+
+	if r.active == nil {
+		return nil, ErrNoActiveKey
+	}
+
+	return r, nil
+}
+
+func (r *KeyRing) Active() *SigningKey {
+	return r.active
+}
+
+// Lookup returns key by kid if tokens signed with it are still accepted
+func (r *KeyRing) Lookup(kid string) (*SigningKey, bool) {
+	key, ok := r.keys[kid]
+	if !ok || !key.canVerify(time.Now()) {
+		return nil, false
+	}
+
+	return key, true
+}
+
+// Keys returns verification keys ordered by kid
+func (r *KeyRing) Keys() []*SigningKey {
+	now := time.Now()
+	keys := make([]*SigningKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key.canVerify(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys
+}
@@ -0,0 +1,189 @@
+package jwt
+
+import (
+	"auth-service/internal/domain/models"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"time"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrKeyMismatch          = errors.New("key type does not match algorithm")
+)
+
+// SigningKey is a private key used to sign tokens together with its key id
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	PrivateKey  crypto.Signer
+	Status      string
+	VerifyUntil time.Time // zero means no limit
+}
+
+// canVerify reports whether tokens signed by the key are still accepted
+func (k *SigningKey) canVerify(now time.Time) bool {
+	return k.VerifyUntil.IsZero() || now.Before(k.VerifyUntil)
+}
+
+func (k *SigningKey) PublicKey() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// LoadSigningKey reads PEM encoded private key from path.
+// If kid is empty, RFC 7638 thumbprint of the public key is used instead.
+func LoadSigningKey(path string, algorithm string, kid string) (*SigningKey, error) {
+	const op = "jwt.LoadSigningKey"
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := ParseSigningKey(data, algorithm, kid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, path, err)
+	}
+
+	return key, nil
+}
+
+// ParseSigningKey parses PEM encoded PKCS#8, PKCS#1 or SEC1 private key
+func ParseSigningKey(data []byte, algorithm string, kid string) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var privateKey any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, ErrKeyMismatch
+	}
+
+	if err := checkAlgorithm(signer, algorithm); err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{
+		ID:         kid,
+		Algorithm:  algorithm,
+		PrivateKey: signer,
+		Status:     models.KeyStatusActive,
+	}
+	if key.ID == "" {
+		key.ID, err = Thumbprint(key.PublicKey())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func checkAlgorithm(signer crypto.Signer, algorithm string) error {
+	switch algorithm {
+	case AlgRS256:
+		if _, ok := signer.(*rsa.PrivateKey); !ok {
+			return ErrKeyMismatch
+		}
+	case AlgES256:
+		ecKey, ok := signer.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return ErrKeyMismatch
+		}
+	case AlgEdDSA:
+		if _, ok := signer.(ed25519.PrivateKey); !ok {
+			return ErrKeyMismatch
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	return nil
+}
+
+// NewSigningKey parses stored key
+func NewSigningKey(model models.SigningKey) (*SigningKey, error) {
+	key, err := ParseSigningKey(model.PrivateKey, model.Algorithm, model.ID)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", model.ID, err)
+	}
+
+	key.Status = model.Status
+	if model.VerifyUntil != nil {
+		key.VerifyUntil = *model.VerifyUntil
+	}
+
+	return key, nil
+}
+
+// GenerateSigningKey creates new pending key, kid is its RFC 7638 thumbprint
+func GenerateSigningKey(algorithm string) (models.SigningKey, error) {
+	var signer crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return models.SigningKey{}, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	kid, err := Thumbprint(signer.Public())
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+
+	return models.SigningKey{
+		ID:         kid,
+		Algorithm:  algorithm,
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		Status:     models.KeyStatusPending,
+		CreatedAt:  time.Now(),
+	}, nil
+}
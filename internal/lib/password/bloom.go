@@ -0,0 +1,182 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Bloom filter file is bloomMagic, bits count, hash functions count and items count
+// as big endian uint64, uint32 and uint64, followed by the bit array
+const (
+	bloomMagic      = "PWBLOOM1"
+	bloomHeaderSize = len(bloomMagic) + 8 + 4 + 8
+)
+
+var ErrInvalidBloomFilter = errors.New("invalid bloom filter file")
+
+// BloomFilter is compact probabilistic set of breached SHA-1 hashes. It never misses added hash,
+// but reports some other passwords as breached with configured false positive rate
+type BloomFilter struct {
+	bits  []byte
+	m     uint64 // bits count
+	k     uint32 // hash functions count
+	items uint64
+}
+
+// NewBloomFilter sizes filter for expected number of hashes and false positive rate
+func NewBloomFilter(expected uint64, falsePositiveRate float64) (*BloomFilter, error) {
+	if expected == 0 {
+		return nil, errors.New("expected number of hashes must be positive")
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, errors.New("false positive rate must be between 0 and 1")
+	}
+
+	m := uint64(math.Ceil(-float64(expected) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint32(max(1, math.Round(float64(m)/float64(expected)*math.Ln2)))
+
+	return &BloomFilter{
+		bits: make([]byte, (m+7)/8),
+		m:    m,
+		k:    k,
+	}, nil
+}
+
+// AddSHA1Hex adds hex encoded SHA-1 hash of breached password
+func (f *BloomFilter) AddSHA1Hex(hash string) error {
+	digest, err := decodeSHA1Hex(hash)
+	if err != nil {
+		return err
+	}
+
+	for _, bit := range bloomBits(digest, f.m, f.k) {
+		f.bits[bit/8] |= 1 << (bit % 8)
+	}
+	f.items++
+
+	return nil
+}
+
+// Size returns bits and hash functions count of filter
+func (f *BloomFilter) Size() (bits uint64, hashes uint32) {
+	return f.m, f.k
+}
+
+// WriteTo writes filter in format read by OpenBloomFilter
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, 0, bloomHeaderSize)
+	header = append(header, bloomMagic...)
+	header = binary.BigEndian.AppendUint64(header, f.m)
+	header = binary.BigEndian.AppendUint32(header, f.k)
+	header = binary.BigEndian.AppendUint64(header, f.items)
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+	written, err := w.Write(f.bits)
+
+	return int64(n + written), err
+}
+
+// BloomFilterFile checks passwords against filter file without loading it to memory,
+// every check reads a few bytes, so frequently used pages stay in OS cache
+type BloomFilterFile struct {
+	file  *os.File
+	m     uint64
+	k     uint32
+	items uint64
+}
+
+// OpenBloomFilter opens filter written by BloomFilter.WriteTo
+func OpenBloomFilter(path string) (*BloomFilterFile, error) {
+	const op = "password.OpenBloomFilter"
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	header := make([]byte, bloomHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidBloomFilter)
+	}
+	if string(header[:len(bloomMagic)]) != bloomMagic {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidBloomFilter)
+	}
+
+	f := &BloomFilterFile{
+		file:  file,
+		m:     binary.BigEndian.Uint64(header[len(bloomMagic):]),
+		k:     binary.BigEndian.Uint32(header[len(bloomMagic)+8:]),
+		items: binary.BigEndian.Uint64(header[len(bloomMagic)+12:]),
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if f.m == 0 || f.k == 0 || info.Size() != int64(bloomHeaderSize)+int64((f.m+7)/8) {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidBloomFilter)
+	}
+
+	return f, nil
+}
+
+// Items returns number of hashes added to filter
+func (f *BloomFilterFile) Items() uint64 {
+	return f.items
+}
+
+func (f *BloomFilterFile) IsBreached(password string) (bool, error) {
+	const op = "password.BloomFilterFile.IsBreached"
+
+	digest := sha1.Sum([]byte(password))
+
+	b := make([]byte, 1)
+	for _, bit := range bloomBits(digest[:], f.m, f.k) {
+		if _, err := f.file.ReadAt(b, int64(bloomHeaderSize)+int64(bit/8)); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		if b[0]&(1<<(bit%8)) == 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (f *BloomFilterFile) Close() error {
+	return f.file.Close()
+}
+
+func decodeSHA1Hex(hash string) ([]byte, error) {
+	digest, err := hex.DecodeString(hash)
+	if err != nil || len(digest) != 20 {
+		return nil, fmt.Errorf("invalid SHA-1 hash %q", hash)
+	}
+	return digest, nil
+}
+
+// bloomBits derives k bit positions from SHA-1 by double hashing, SHA-1 is already uniform,
+// so its two halves serve as independent hash functions
+func bloomBits(digest []byte, m uint64, k uint32) []uint64 {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+
+	bits := make([]uint64, k)
+	for i := range bits {
+		bits[i] = (h1 + uint64(i)*h2) % m
+	}
+	return bits
+}
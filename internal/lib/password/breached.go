@@ -0,0 +1,103 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BreachChecker reports whether password is known from breach corpora
+type BreachChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// SHA1Hex is uppercase hex SHA-1 of password, the form breach datasets are keyed by
+func SHA1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// ParseHashCount parses "<hex hash>:<count>" line of HIBP dataset, hash is a full SHA-1
+// in combined dataset and 35 chars suffix in range files
+func ParseHashCount(line string) (string, int, error) {
+	hash, countStr, found := strings.Cut(strings.TrimSpace(line), ":")
+	if !found {
+		return "", 0, fmt.Errorf("invalid line %q", line)
+	}
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid count in line %q", line)
+	}
+
+	return strings.ToUpper(hash), count, nil
+}
+
+// RangeDir checks passwords against HIBP range files, <dir>/<first 5 hex chars of SHA-1>.txt
+// with "<remaining 35 chars>:<count>" lines as served by the k-anonymity range API
+type RangeDir struct {
+	dir      string
+	minCount int
+}
+
+// NewRangeDir opens range dataset, passwords seen less than minCount times are accepted.
+// minCount below 1 is raised to 1, so zero count padding entries never match
+func NewRangeDir(dir string, minCount int) (*RangeDir, error) {
+	const op = "password.NewRangeDir"
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s: %s is not a directory", op, dir)
+	}
+
+	return &RangeDir{
+		dir:      dir,
+		minCount: max(minCount, 1),
+	}, nil
+}
+
+func (r *RangeDir) IsBreached(password string) (bool, error) {
+	const op = "password.RangeDir.IsBreached"
+
+	hash := SHA1Hex(password)
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(r.dir, prefix+".txt"))
+	if err != nil {
+		// complete dataset has a file for every prefix, partial one just does not know the password
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		lineSuffix, count, err := ParseHashCount(scanner.Text())
+		if err != nil {
+			return false, fmt.Errorf("%s: %s: %w", op, file.Name(), err)
+		}
+		if lineSuffix == suffix {
+			return count >= r.minCount, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,180 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// BcryptMaxBytes is the input length bcrypt fails on instead of hashing
+const BcryptMaxBytes = 72
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Argon2Params tune argon2id, Memory is in KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params are OWASP recommended minimum
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hasher hashes new passwords with configured algorithm and verifies hashes of both supported ones.
+// Argon2id hashes are PHC strings "$argon2id$v=19$m=<KiB>,t=<iterations>,p=<parallelism>$<salt>$<key>",
+// bcrypt keeps its own "$2a$<cost>$..." form, so hashes stored before hasher existed stay valid
+type Hasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     Argon2Params
+}
+
+func NewHasher(algorithm string, bcryptCost int, argon2Params Argon2Params) (*Hasher, error) {
+	const op = "password.NewHasher"
+
+	switch algorithm {
+	case AlgorithmArgon2id:
+		if argon2Params.Memory < 8*uint32(argon2Params.Parallelism) || argon2Params.Iterations < 1 ||
+			argon2Params.Parallelism < 1 || argon2Params.SaltLength < 8 || argon2Params.KeyLength < 16 {
+			return nil, fmt.Errorf("%s: invalid argon2id parameters %+v", op, argon2Params)
+		}
+	case AlgorithmBcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("%s: bcrypt cost must be between %d and %d", op, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown algorithm %q", op, algorithm)
+	}
+
+	return &Hasher{
+		algorithm:  algorithm,
+		bcryptCost: bcryptCost,
+		argon2:     argon2Params,
+	}, nil
+}
+
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	const op = "password.Hasher.Hash"
+
+	if h.algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return hash, nil
+	}
+
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.argon2.Iterations, h.argon2.Memory, h.argon2.Parallelism, h.argon2.KeyLength)
+
+	return []byte(encodeArgon2id(h.argon2, salt, key)), nil
+}
+
+// Verify compares password with hash. needsRehash is set for matching password
+// whose hash uses other algorithm or parameters than configured ones
+func (h *Hasher) Verify(password string, hash []byte) (match bool, needsRehash bool, err error) {
+	const op = "password.Hasher.Verify"
+
+	encoded := string(hash)
+
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return false, false, nil
+		}
+
+		return true, h.algorithm != AlgorithmArgon2id || params != h.argon2, nil
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		cost, err := bcrypt.Cost(hash)
+		if err != nil {
+			return false, false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return true, h.algorithm != AlgorithmBcrypt || cost != h.bcryptCost, nil
+	default:
+		return false, false, fmt.Errorf("%s: %w", op, ErrUnknownHash)
+	}
+}
+
+func encodeArgon2id(params Argon2Params, salt []byte, key []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		params.Memory,
+		params.Iterations,
+		params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2id(encoded string) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	var params Argon2Params
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations < 1 || params.Parallelism < 1 {
+		return Argon2Params{}, nil, nil, fmt.Errorf("invalid argon2 parameters %q", parts[3])
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("invalid argon2 key: %w", err)
+	}
+	if len(key) == 0 {
+		return Argon2Params{}, nil, nil, errors.New("empty argon2 key")
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,249 @@
+package password
+
+import (
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// testArgon2Params are cheap, so tests do not spend 19 MiB per hash
+var testArgon2Params = Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func newTestHasher(t *testing.T, algorithm string, bcryptCost int, params Argon2Params) *Hasher {
+	t.Helper()
+
+	h, err := NewHasher(algorithm, bcryptCost, params)
+	if err != nil {
+		t.Fatalf("NewHasher() error = %v", err)
+	}
+	return h
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		format *regexp.Regexp
+	}{
+		{
+			"argon2id",
+			newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params),
+			regexp.MustCompile(`^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`),
+		},
+		{
+			"bcrypt",
+			newTestHasher(t, AlgorithmBcrypt, 4, Argon2Params{}),
+			regexp.MustCompile(`^\$2a\$04\$[./A-Za-z0-9]{53}$`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hasher.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			if !tt.format.Match(hash) {
+				t.Errorf("Hash() = %q, unexpected format", hash)
+			}
+
+			match, needsRehash, err := tt.hasher.Verify("correct horse", hash)
+			if err != nil || !match || needsRehash {
+				t.Errorf("Verify(correct) = %v, %v, %v, want true, false, nil", match, needsRehash, err)
+			}
+
+			match, needsRehash, err = tt.hasher.Verify("wrong horse", hash)
+			if err != nil || match || needsRehash {
+				t.Errorf("Verify(wrong) = %v, %v, %v, want false, false, nil", match, needsRehash, err)
+			}
+		})
+	}
+}
+
+func TestHasherVerifyPHC(t *testing.T) {
+	// hash built outside of Hasher, so PHC parsing is checked independently from encoding
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte("secret"), salt, 3, 32, 2, 24)
+	hash := "$argon2id$v=19$m=32,t=3,p=2$" + base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(key)
+
+	h := newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params)
+	match, needsRehash, err := h.Verify("secret", []byte(hash))
+	if err != nil || !match {
+		t.Fatalf("Verify() = %v, %v, want match", match, err)
+	}
+	if !needsRehash {
+		t.Error("Verify() needsRehash = false for other parameters")
+	}
+
+	params, _, _, err := decodeArgon2id(hash)
+	if err != nil {
+		t.Fatalf("decodeArgon2id() error = %v", err)
+	}
+	want := Argon2Params{Memory: 32, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 24}
+	if params != want {
+		t.Errorf("decodeArgon2id() params = %+v, want %+v", params, want)
+	}
+}
+
+func TestHasherVerifyMalformed(t *testing.T) {
+	const salt, key = "MDEyMzQ1Njc4OWFiY2RlZg", "c2VjcmV0c2VjcmV0c2VjcmV0"
+
+	tests := []struct {
+		name    string
+		hash    string
+		unknown bool
+	}{
+		{"empty", "", true},
+		{"plain text", "secret", true},
+		{"scrypt", "$scrypt$ln=16,r=8,p=1$" + salt + "$" + key, true},
+		{"argon2i", "$argon2i$v=19$m=64,t=1,p=1$" + salt + "$" + key, true},
+		{"missing key", "$argon2id$v=19$m=64,t=1,p=1$" + salt, true},
+		{"old argon2 version", "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key, false},
+		{"invalid parameters", "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key, false},
+		{"garbage parameters", "$argon2id$v=19$memory$" + salt + "$" + key, false},
+		{"invalid salt", "$argon2id$v=19$m=64,t=1,p=1$!!!$" + key, false},
+		{"empty key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$", false},
+		{"truncated bcrypt", "$2a$04$short", false},
+	}
+
+	h := newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, _, err := h.Verify("secret", []byte(tt.hash))
+			if err == nil || match {
+				t.Fatalf("Verify() = %v, %v, want error", match, err)
+			}
+			if tt.unknown && !errors.Is(err, ErrUnknownHash) {
+				t.Errorf("Verify() error = %v, want ErrUnknownHash", err)
+			}
+		})
+	}
+}
+
+func TestHasherNeedsRehash(t *testing.T) {
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	larger := testArgon2Params
+	larger.Memory = 128
+	longerSalt := testArgon2Params
+	longerSalt.SaltLength = 32
+
+	tests := []struct {
+		name        string
+		hashedWith  *Hasher
+		verifiedBy  *Hasher
+		needsRehash bool
+	}{
+		{"same argon2id parameters", newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params), newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params), false},
+		{"argon2id iterations raised", newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params), newTestHasher(t, AlgorithmArgon2id, 0, stronger), true},
+		{"argon2id memory raised", newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params), newTestHasher(t, AlgorithmArgon2id, 0, larger), true},
+		{"argon2id salt length changed", newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params), newTestHasher(t, AlgorithmArgon2id, 0, longerSalt), true},
+		{"same bcrypt cost", newTestHasher(t, AlgorithmBcrypt, 4, Argon2Params{}), newTestHasher(t, AlgorithmBcrypt, 4, Argon2Params{}), false},
+		{"bcrypt cost raised", newTestHasher(t, AlgorithmBcrypt, 4, Argon2Params{}), newTestHasher(t, AlgorithmBcrypt, 5, Argon2Params{}), true},
+		{"bcrypt to argon2id", newTestHasher(t, AlgorithmBcrypt, 4, Argon2Params{}), newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params), true},
+		{"argon2id to bcrypt", newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params), newTestHasher(t, AlgorithmBcrypt, 4, Argon2Params{}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hashedWith.Hash("secret")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+
+			match, needsRehash, err := tt.verifiedBy.Verify("secret", hash)
+			if err != nil || !match {
+				t.Fatalf("Verify() = %v, %v, want match", match, err)
+			}
+			if needsRehash != tt.needsRehash {
+				t.Errorf("Verify() needsRehash = %v, want %v", needsRehash, tt.needsRehash)
+			}
+		})
+	}
+}
+
+func TestBcryptMaxBytes(t *testing.T) {
+	h := newTestHasher(t, AlgorithmBcrypt, 4, Argon2Params{})
+	policy := Policy{MaxBytes: BcryptMaxBytes}
+
+	longest := strings.Repeat("a", BcryptMaxBytes)
+	if err := policy.Validate(longest); err != nil {
+		t.Errorf("Validate(%d bytes) error = %v", BcryptMaxBytes, err)
+	}
+	hash, err := h.Hash(longest)
+	if err != nil {
+		t.Fatalf("Hash(%d bytes) error = %v", BcryptMaxBytes, err)
+	}
+	if match, _, err := h.Verify(longest, hash); err != nil || !match {
+		t.Errorf("Verify(%d bytes) = %v, %v, want match", BcryptMaxBytes, match, err)
+	}
+
+	// bcrypt refuses what policy lets through if PASSWORD_MAX_BYTES is above the limit
+	tooLong := longest + "a"
+	if _, err := h.Hash(tooLong); err == nil {
+		t.Errorf("Hash(%d bytes) error = nil", len(tooLong))
+	}
+
+	tests := []struct {
+		name     string
+		password string
+	}{
+		{"ascii", tooLong},
+		// 37 characters take 74 bytes, so character limits alone do not protect bcrypt
+		{"multibyte", strings.Repeat("é", 37)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var policyErr *PolicyError
+			if err := policy.Validate(tt.password); !errors.As(err, &policyErr) {
+				t.Fatalf("Validate() error = %v, want *PolicyError", err)
+			}
+			if len(policyErr.Violations) != 1 || policyErr.Violations[0].Rule != RuleMaxBytes {
+				t.Errorf("Validate() violations = %+v, want %s", policyErr.Violations, RuleMaxBytes)
+			}
+		})
+	}
+}
+
+func TestNewHasherValidation(t *testing.T) {
+	lowMemory := testArgon2Params
+	lowMemory.Memory = 4
+	shortSalt := testArgon2Params
+	shortSalt.SaltLength = 4
+	shortKey := testArgon2Params
+	shortKey.KeyLength = 8
+
+	tests := []struct {
+		name       string
+		algorithm  string
+		bcryptCost int
+		params     Argon2Params
+	}{
+		{"unknown algorithm", "scrypt", 10, testArgon2Params},
+		{"bcrypt cost too low", AlgorithmBcrypt, 3, testArgon2Params},
+		{"bcrypt cost too high", AlgorithmBcrypt, 32, testArgon2Params},
+		{"argon2id zero parameters", AlgorithmArgon2id, 10, Argon2Params{}},
+		{"argon2id memory below 8 KiB per lane", AlgorithmArgon2id, 10, lowMemory},
+		{"argon2id short salt", AlgorithmArgon2id, 10, shortSalt},
+		{"argon2id short key", AlgorithmArgon2id, 10, shortKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHasher(tt.algorithm, tt.bcryptCost, tt.params); err == nil {
+				t.Error("NewHasher() error = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package password
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const pepperPrefix = "$pepper$v="
+
+// minPepperLength is HMAC-SHA256 output size
+const minPepperLength = 32
+
+var ErrUnknownPepper = errors.New("password hash uses unknown pepper version")
+
+// PepperedHasher keys password with HMAC-SHA256 pepper kept outside of database before hashing it,
+// so leaked hashes can not be cracked without the pepper. Pepper version is stored in front of the hash:
+// "$pepper$v=<version>$argon2id$v=19$...". Versions let peppers rotate: hashes of older
+// or no pepper keep working and are re-peppered with current one on successful login
+type PepperedHasher struct {
+	hasher  *Hasher
+	peppers map[int][]byte
+	current int // 0 means new hashes are not peppered
+}
+
+func NewPepperedHasher(hasher *Hasher, peppers map[int][]byte, current int) (*PepperedHasher, error) {
+	const op = "password.NewPepperedHasher"
+
+	for version, key := range peppers {
+		if version <= 0 {
+			return nil, fmt.Errorf("%s: pepper version must be positive, got %d", op, version)
+		}
+		if len(key) < minPepperLength {
+			return nil, fmt.Errorf("%s: pepper %d must be at least %d bytes", op, version, minPepperLength)
+		}
+	}
+	if _, ok := peppers[current]; current != 0 && !ok {
+		return nil, fmt.Errorf("%s: current pepper %d is not configured", op, current)
+	}
+
+	return &PepperedHasher{
+		hasher:  hasher,
+		peppers: peppers,
+		current: current,
+	}, nil
+}
+
+func (h *PepperedHasher) Hash(password string) ([]byte, error) {
+	if h.current == 0 {
+		return h.hasher.Hash(password)
+	}
+
+	hash, err := h.hasher.Hash(pepper(h.peppers[h.current], password))
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(pepperPrefix+strconv.Itoa(h.current)), hash...), nil
+}
+
+// Verify compares password with hash, needsRehash is also set for matching password peppered with not current pepper
+func (h *PepperedHasher) Verify(password string, hash []byte) (match bool, needsRehash bool, err error) {
+	const op = "password.PepperedHasher.Verify"
+
+	encoded := string(hash)
+	if !strings.HasPrefix(encoded, pepperPrefix) {
+		match, needsRehash, err = h.hasher.Verify(password, hash)
+		return match, match && (needsRehash || h.current != 0), err
+	}
+
+	versionStr, inner, found := strings.Cut(strings.TrimPrefix(encoded, pepperPrefix), "$")
+	version, err := strconv.Atoi(versionStr)
+	if !found || err != nil {
+		return false, false, fmt.Errorf("%s: %w", op, ErrUnknownHash)
+	}
+
+	key, ok := h.peppers[version]
+	if !ok {
+		return false, false, fmt.Errorf("%s: %w: %d", op, ErrUnknownPepper, version)
+	}
+
+	match, needsRehash, err = h.hasher.Verify(pepper(key, password), []byte("$"+inner))
+	return match, match && (needsRehash || version != h.current), err
+}
+
+// pepper is base64 of HMAC, so bcrypt gets 44 printable bytes whatever length password has
+func pepper(key []byte, password string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ParsePeppers decodes version -> base64 key pairs
+func ParsePeppers(encoded map[string]string) (map[int][]byte, error) {
+	peppers := make(map[int][]byte, len(encoded))
+
+	for versionStr, keyStr := range encoded {
+		version, err := strconv.Atoi(strings.TrimSpace(versionStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pepper version %q", versionStr)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(keyStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pepper %d: %w", version, err)
+		}
+		peppers[version] = key
+	}
+
+	return peppers, nil
+}
+
+// LoadPeppers reads file of "<version>:<base64 key>" lines, empty and # lines are skipped
+func LoadPeppers(path string) (map[int][]byte, error) {
+	const op = "password.LoadPeppers"
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer file.Close()
+
+	encoded := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		version, key, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("%s: line without version", op)
+		}
+		if _, exists := encoded[version]; exists {
+			return nil, fmt.Errorf("%s: duplicate pepper version %s", op, version)
+		}
+		encoded[version] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	peppers, err := ParsePeppers(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return peppers, nil
+}
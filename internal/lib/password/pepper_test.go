@@ -0,0 +1,187 @@
+package password
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var (
+	testPepper1 = bytes.Repeat([]byte{1}, minPepperLength)
+	testPepper2 = bytes.Repeat([]byte{2}, minPepperLength)
+)
+
+func newTestPepperedHasher(t *testing.T, hasher *Hasher, peppers map[int][]byte, current int) *PepperedHasher {
+	t.Helper()
+
+	h, err := NewPepperedHasher(hasher, peppers, current)
+	if err != nil {
+		t.Fatalf("NewPepperedHasher() error = %v", err)
+	}
+	return h
+}
+
+func TestPepperedHasherRoundTrip(t *testing.T) {
+	hasher := newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params)
+	h := newTestPepperedHasher(t, hasher, map[int][]byte{1: testPepper1}, 1)
+
+	hash, err := h.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if !strings.HasPrefix(string(hash), "$pepper$v=1$argon2id$") {
+		t.Errorf("Hash() = %q, want $pepper$v=1$argon2id$ prefix", hash)
+	}
+
+	match, needsRehash, err := h.Verify("secret", hash)
+	if err != nil || !match || needsRehash {
+		t.Errorf("Verify(correct) = %v, %v, %v, want true, false, nil", match, needsRehash, err)
+	}
+
+	match, _, err = h.Verify("wrong", hash)
+	if err != nil || match {
+		t.Errorf("Verify(wrong) = %v, %v, want false, nil", match, err)
+	}
+
+	// without pepper the inner hash is useless
+	if match, _, _ := hasher.Verify("secret", hash[len("$pepper$v=1"):]); match {
+		t.Error("inner hash matched unpeppered password")
+	}
+}
+
+func TestPepperedHasherVerify(t *testing.T) {
+	hasher := newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params)
+	peppers := map[int][]byte{1: testPepper1, 2: testPepper2}
+
+	hashWith := func(current int) []byte {
+		hash, err := newTestPepperedHasher(t, hasher, peppers, current).Hash("secret")
+		if err != nil {
+			t.Fatalf("Hash() error = %v", err)
+		}
+		return hash
+	}
+	legacy, v1, v2 := hashWith(0), hashWith(1), hashWith(2)
+
+	tests := []struct {
+		name        string
+		current     int
+		password    string
+		hash        []byte
+		match       bool
+		needsRehash bool
+		err         error
+	}{
+		{"current pepper", 2, "secret", v2, true, false, nil},
+		{"rotated pepper", 2, "secret", v1, true, true, nil},
+		{"rotated pepper wrong password", 2, "wrong", v1, false, false, nil},
+		{"legacy unpeppered", 2, "secret", legacy, true, true, nil},
+		{"legacy unpeppered wrong password", 2, "wrong", legacy, false, false, nil},
+		{"legacy unpeppered without pepper", 0, "secret", legacy, true, false, nil},
+		{"pepper disabled", 0, "secret", v2, true, true, nil},
+		{"unknown version", 2, "secret", []byte(strings.Replace(string(v2), "v=2", "v=3", 1)), false, false, ErrUnknownPepper},
+		{"non numeric version", 2, "secret", []byte(strings.Replace(string(v2), "v=2", "v=x", 1)), false, false, ErrUnknownHash},
+		{"missing inner hash", 2, "secret", []byte("$pepper$v=2"), false, false, ErrUnknownHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestPepperedHasher(t, hasher, peppers, tt.current)
+
+			match, needsRehash, err := h.Verify(tt.password, tt.hash)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.err)
+			}
+			if match != tt.match || needsRehash != tt.needsRehash {
+				t.Errorf("Verify() = %v, %v, want %v, %v", match, needsRehash, tt.match, tt.needsRehash)
+			}
+		})
+	}
+}
+
+func TestPepperedHasherBcryptLongPassword(t *testing.T) {
+	hasher := newTestHasher(t, AlgorithmBcrypt, 4, Argon2Params{})
+	h := newTestPepperedHasher(t, hasher, map[int][]byte{1: testPepper1}, 1)
+
+	// passwords sharing first 72 bytes must not collide once peppered
+	long := strings.Repeat("a", 2*BcryptMaxBytes)
+	hash, err := h.Hash(long + "1")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if match, _, err := h.Verify(long+"1", hash); err != nil || !match {
+		t.Errorf("Verify(same) = %v, %v, want match", match, err)
+	}
+	if match, _, err := h.Verify(long+"2", hash); err != nil || match {
+		t.Errorf("Verify(other suffix) = %v, %v, want no match", match, err)
+	}
+}
+
+func TestNewPepperedHasherValidation(t *testing.T) {
+	hasher := newTestHasher(t, AlgorithmArgon2id, 0, testArgon2Params)
+
+	tests := []struct {
+		name    string
+		peppers map[int][]byte
+		current int
+	}{
+		{"zero version", map[int][]byte{0: testPepper1}, 0},
+		{"negative version", map[int][]byte{-1: testPepper1}, 0},
+		{"short pepper", map[int][]byte{1: testPepper1[:minPepperLength-1]}, 1},
+		{"current not configured", map[int][]byte{1: testPepper1}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPepperedHasher(hasher, tt.peppers, tt.current); err == nil {
+				t.Error("NewPepperedHasher() error = nil")
+			}
+		})
+	}
+}
+
+func TestLoadPeppers(t *testing.T) {
+	const key1 = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+	const key2 = "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI="
+
+	tests := []struct {
+		name    string
+		content string
+		want    map[int][]byte
+		wantErr bool
+	}{
+		{
+			name:    "versions with comments",
+			content: "# rotated 2024\n1:" + key1 + "\n\n 2 : " + key2 + "\n",
+			want:    map[int][]byte{1: testPepper1, 2: testPepper2},
+		},
+		{name: "duplicate version", content: "1:" + key1 + "\n1:" + key2 + "\n", wantErr: true},
+		{name: "line without version", content: key1 + "\n", wantErr: true},
+		{name: "non numeric version", content: "one:" + key1 + "\n", wantErr: true},
+		{name: "invalid base64", content: "1:not base64!\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "peppers")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			got, err := LoadPeppers(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPeppers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("LoadPeppers() = %d peppers, want %d", len(got), len(tt.want))
+			}
+			for version, key := range tt.want {
+				if !bytes.Equal(got[version], key) {
+					t.Errorf("LoadPeppers()[%d] = %x, want %x", version, got[version], key)
+				}
+			}
+		})
+	}
+}
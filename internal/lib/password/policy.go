@@ -0,0 +1,196 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules reported in violations, values are stable and can be used by clients
+const (
+	RuleMinLength      = "MIN_LENGTH"
+	RuleMaxLength      = "MAX_LENGTH"
+	RuleMaxBytes       = "MAX_BYTES"
+	RuleLowercase      = "LOWERCASE_REQUIRED"
+	RuleUppercase      = "UPPERCASE_REQUIRED"
+	RuleDigit          = "DIGIT_REQUIRED"
+	RuleSymbol         = "SYMBOL_REQUIRED"
+	RuleCharClasses    = "CHAR_CLASSES"
+	RulePersonalInfo   = "PERSONAL_INFO"
+	RuleCommonPassword = "COMMON_PASSWORD"
+	RuleBreached       = "BREACHED_PASSWORD"
+)
+
+//go:embed common-passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = parseCommonPasswords(commonPasswordsFile)
+
+// Violation is a single failed rule of password policy
+type Violation struct {
+	Rule        string
+	Description string
+}
+
+// PolicyError lists every rule password failed, so user can fix them at once
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	descriptions := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		descriptions = append(descriptions, v.Description)
+	}
+	return "password does not satisfy policy: " + strings.Join(descriptions, "; ")
+}
+
+// Policy of acceptable passwords, zero value of a rule disables it.
+// Lengths are counted in characters, MaxBytes in bytes of UTF-8 encoding
+type Policy struct {
+	MinLength      int
+	MaxLength      int
+	MaxBytes       int
+	RequireLower   bool
+	RequireUpper   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	MinCharClasses int // of lowercase, uppercase, digits and symbols
+	RejectPersonal bool
+	RejectCommon   bool
+	Breached       BreachChecker // optional
+}
+
+// Validate checks password against policy, personal are email and username of its owner.
+// Rejected password results in *PolicyError, other errors mean breach check failed
+func (p *Policy) Validate(password string, personal ...string) error {
+	const op = "password.Policy.Validate"
+
+	var violations []Violation
+	violate := func(rule string, format string, args ...any) {
+		violations = append(violations, Violation{Rule: rule, Description: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		violate(RuleMinLength, "must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violate(RuleMaxLength, "must be at most %d characters long", p.MaxLength)
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violate(RuleMaxBytes, "must be at most %d bytes long, non-latin characters take several bytes", p.MaxBytes)
+	}
+
+	classes := charClasses(password)
+	if p.RequireLower && !classes.lower {
+		violate(RuleLowercase, "must contain a lowercase letter")
+	}
+	if p.RequireUpper && !classes.upper {
+		violate(RuleUppercase, "must contain an uppercase letter")
+	}
+	if p.RequireDigit && !classes.digit {
+		violate(RuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !classes.symbol {
+		violate(RuleSymbol, "must contain a symbol")
+	}
+	if p.MinCharClasses > 0 && classes.count() < p.MinCharClasses {
+		violate(RuleCharClasses, "must contain at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinCharClasses)
+	}
+
+	if p.RejectPersonal && containsPersonal(password, personal) {
+		violate(RulePersonalInfo, "must not contain email or username")
+	}
+	if p.RejectCommon {
+		if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+			violate(RuleCommonPassword, "is too common")
+		}
+	}
+	if p.Breached != nil {
+		breached, err := p.Breached.IsBreached(password)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if breached {
+			violate(RuleBreached, "has appeared in a data breach")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+type classes struct {
+	lower, upper, digit, symbol bool
+}
+
+func (c classes) count() int {
+	n := 0
+	for _, present := range []bool{c.lower, c.upper, c.digit, c.symbol} {
+		if present {
+			n++
+		}
+	}
+	return n
+}
+
+func charClasses(password string) classes {
+	var c classes
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			c.lower = true
+		case unicode.IsUpper(r):
+			c.upper = true
+		case unicode.IsDigit(r):
+			c.digit = true
+		default:
+			c.symbol = true
+		}
+	}
+	return c
+}
+
+// containsPersonal reports whether password contains email, its local part or username.
+// Parts shorter than 4 characters are too likely to match by accident
+func containsPersonal(password string, personal []string) bool {
+	lowered := strings.ToLower(password)
+
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		candidates := []string{value}
+		if local, _, found := strings.Cut(value, "@"); found {
+			candidates = append(candidates, local)
+		}
+
+		for _, candidate := range candidates {
+			if utf8.RuneCountInString(candidate) >= 4 && strings.Contains(lowered, candidate) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func parseCommonPasswords(data string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+
+	return passwords
+}
@@ -0,0 +1,60 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+var ErrDecrypt = errors.New("can not decrypt secret")
+
+// Cipher encrypts secrets stored in database with AES-256-GCM, random nonce is prepended to ciphertext.
+// Associated data, e.g. owner id, binds ciphertext to its row, so it can not be copied to another one
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates cipher from 32 bytes key
+func NewCipher(key []byte) (*Cipher, error) {
+	const op = "secret.NewCipher"
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s: key must be 32 bytes, got %d", op, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+func (c *Cipher) Encrypt(plaintext []byte, associatedData []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("secret.Cipher.Encrypt: %w", err)
+	}
+
+	return c.aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+func (c *Cipher) Decrypt(ciphertext []byte, associatedData []byte) ([]byte, error) {
+	if len(ciphertext) < c.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, sealed := ciphertext[:c.aead.NonceSize()], ciphertext[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, associatedData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,87 @@
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, fill byte) *Cipher {
+	t.Helper()
+
+	c, err := NewCipher(bytes.Repeat([]byte{fill}, 32))
+	if err != nil {
+		t.Fatalf("NewCipher() error = %v", err)
+	}
+	return c
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, 1)
+	plaintext := []byte("JBSWY3DPEHPK3PXP")
+
+	first, err := c.Encrypt(plaintext, []byte("totp:1"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	second, err := c.Encrypt(plaintext, []byte("totp:1"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("Encrypt() reused nonce")
+	}
+	if bytes.Contains(first, plaintext) {
+		t.Error("Encrypt() leaked plaintext")
+	}
+
+	got, err := c.Decrypt(first, []byte("totp:1"))
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt() = %q, want %q", got, plaintext)
+	}
+}
+
+func TestCipherDecryptRejects(t *testing.T) {
+	c := newTestCipher(t, 1)
+	ciphertext, err := c.Encrypt([]byte("JBSWY3DPEHPK3PXP"), []byte("totp:1"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	tampered := bytes.Clone(ciphertext)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name           string
+		cipher         *Cipher
+		ciphertext     []byte
+		associatedData []byte
+	}{
+		// secret copied to another user's row must not decrypt
+		{"other user", c, ciphertext, []byte("totp:2")},
+		{"missing associated data", c, ciphertext, nil},
+		{"other key", newTestCipher(t, 2), ciphertext, []byte("totp:1")},
+		{"tampered ciphertext", c, tampered, []byte("totp:1")},
+		{"truncated ciphertext", c, ciphertext[:len(ciphertext)-1], []byte("totp:1")},
+		{"shorter than nonce", c, ciphertext[:4], []byte("totp:1")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cipher.Decrypt(tt.ciphertext, tt.associatedData); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("Decrypt() error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestNewCipherKeyLength(t *testing.T) {
+	for _, size := range []int{0, 16, 24, 31, 33} {
+		if _, err := NewCipher(make([]byte, size)); err == nil {
+			t.Errorf("NewCipher(%d bytes) error = nil", size)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// Generate returns url-safe random string made of n random bytes
+func Generate(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b) // never returns an error
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Hash returns hex encoded SHA-256 of high entropy secret.
+// It is meant for random tokens only, passwords must be hashed with a slow KDF
+func Hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,82 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RFC 6238 parameters every authenticator app supports
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// Skew is number of neighbour time steps accepted to tolerate clock drift
+	Skew = 1
+)
+
+const secretSize = 20
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns random base32 secret of 160 bits, the size RFC 4226 recommends
+func GenerateSecret() string {
+	b := make([]byte, secretSize)
+	_, _ = rand.Read(b) // never returns an error
+	return encoding.EncodeToString(b)
+}
+
+// URI returns otpauth:// key URI authenticator apps import from QR code
+func URI(issuer string, account string, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+
+	// some apps show "+" of form encoding literally
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+// Validate checks code against secret at time now and returns time step it matched,
+// callers must reject steps not after the last used one, so a code can not be replayed
+func Validate(secret string, code string, now time.Time) (step int64, ok bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+
+	current := now.Unix() / int64(Period.Seconds())
+	for s := current - Skew; s <= current+Skew; s++ {
+		if subtle.ConstantTimeCompare([]byte(generate(key, s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+
+	return 0, false
+}
+
+// generate is HOTP of RFC 4226 for counter step
+func generate(key []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%uint32(math.Pow10(Digits)))
+}
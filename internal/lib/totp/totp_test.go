@@ -0,0 +1,107 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is base32 of the SHA1 seed "12345678901234567890" of RFC 6238 appendix B
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfc6238Vectors are SHA1 vectors of RFC 6238 appendix B, truncated from 8 to 6 digits
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestGenerateRFC6238(t *testing.T) {
+	key, err := encoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("DecodeString() error = %v", err)
+	}
+
+	for _, tt := range rfc6238Vectors {
+		step := tt.unix / int64(Period.Seconds())
+		if got := generate(key, step); got != tt.code {
+			t.Errorf("generate(T=%d) = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	step := at.Unix() / int64(Period.Seconds())
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		now      time.Time
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfc6238Secret, "050471", at, step, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050471", at, step, true},
+		{"previous step within skew", rfc6238Secret, "050471", at.Add(Period), step, true},
+		{"next step within skew", rfc6238Secret, "050471", at.Add(-Period), step, true},
+		{"outside skew", rfc6238Secret, "050471", at.Add(2 * Period), 0, false},
+		{"wrong code", rfc6238Secret, "050472", at, 0, false},
+		{"eight digit code", rfc6238Secret, "14050471", at, 0, false},
+		{"invalid secret", "not base32!", "050471", at, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOK := Validate(tt.secret, tt.code, tt.now)
+			if gotStep != tt.wantStep || gotOK != tt.wantOK {
+				t.Errorf("Validate() = %d, %v, want %d, %v", gotStep, gotOK, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret := GenerateSecret()
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("GenerateSecret() = %q, decode error = %v", secret, err)
+	}
+	if len(key) != secretSize {
+		t.Errorf("GenerateSecret() = %d bytes, want %d", len(key), secretSize)
+	}
+	if GenerateSecret() == secret {
+		t.Error("GenerateSecret() returned same secret twice")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("Smart API", "user@example.com", rfc6238Secret))
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Smart API:user@example.com" {
+		t.Errorf("URI() = %s, unexpected label", uri)
+	}
+	query := uri.Query()
+	for key, want := range map[string]string{
+		"secret":    rfc6238Secret,
+		"issuer":    "Smart API",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("URI() %s = %q, want %q", key, got, want)
+		}
+	}
+}
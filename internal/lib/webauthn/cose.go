@@ -0,0 +1,121 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithms accepted for credentials, in order of preference
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters of RFC 9053
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+// minRSABits rejects keys too weak to trust
+const minRSABits = 2048
+
+// publicKey is credential public key decoded from COSE_Key
+type publicKey struct {
+	alg int64
+	key any // *ecdsa.PublicKey, ed25519.PublicKey or *rsa.PublicKey
+}
+
+// parsePublicKey decodes COSE_Key stored with credential
+func parsePublicKey(coseKey []byte) (publicKey, error) {
+	decoded, rest, err := decodeCBOR(coseKey)
+	if err != nil {
+		return publicKey{}, err
+	}
+	if len(rest) != 0 {
+		return publicKey{}, fmt.Errorf("%w: trailing bytes after key", errCBOR)
+	}
+	return coseKeyFromMap(decoded)
+}
+
+func coseKeyFromMap(decoded any) (publicKey, error) {
+	params, ok := decoded.(map[any]any)
+	if !ok {
+		return publicKey{}, errors.New("cose key is not a map")
+	}
+
+	kty, _ := params[int64(coseKty)].(int64)
+	alg, _ := params[int64(coseAlg)].(int64)
+
+	switch alg {
+	case AlgES256:
+		crv, _ := params[int64(coseCrv)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		y, _ := params[int64(coseY)].([]byte)
+		if kty != coseKtyEC2 || crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return publicKey{}, errors.New("invalid ES256 key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return publicKey{}, errors.New("ES256 key is not on curve")
+		}
+		return publicKey{alg: alg, key: key}, nil
+	case AlgEdDSA:
+		crv, _ := params[int64(coseCrv)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		if kty != coseKtyOKP || crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, errors.New("invalid EdDSA key")
+		}
+		return publicKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+	case AlgRS256:
+		n, _ := params[int64(coseN)].([]byte)
+		e, _ := params[int64(coseE)].([]byte)
+		if kty != coseKtyRSA || len(n)*8 < minRSABits || len(e) == 0 || len(e) > 4 {
+			return publicKey{}, errors.New("invalid RS256 key")
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		if exponent < 3 || exponent%2 == 0 {
+			return publicKey{}, errors.New("invalid RS256 key exponent")
+		}
+		return publicKey{alg: alg, key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}}, nil
+	default:
+		return publicKey{}, fmt.Errorf("unsupported algorithm %d", alg)
+	}
+}
+
+func (k publicKey) verify(data []byte, signature []byte) bool {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
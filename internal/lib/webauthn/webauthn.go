@@ -0,0 +1,530 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// UserVerification values of options, required makes authenticator check PIN or biometrics
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+// authenticator data flags
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackedUp       = 0x10
+	flagAttestedData   = 0x40
+	flagExtensionData  = 0x80
+)
+
+const (
+	challengeSize      = 32
+	maxCredentialIDLen = 1023
+)
+
+var (
+	ErrInvalidResponse = errors.New("invalid webauthn response")
+	ErrSignCount       = errors.New("webauthn signature counter did not increase")
+)
+
+// RelyingParty verifies ceremonies of one site. ID is domain credentials are scoped to,
+// Origins are exact origins browsers report for pages calling WebAuthn, e.g. "https://app.example.com".
+// Timeout is how long user has to complete ceremony
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+	Timeout time.Duration
+}
+
+func NewRelyingParty(id string, name string, origins []string, timeout time.Duration) (*RelyingParty, error) {
+	const op = "webauthn.NewRelyingParty"
+
+	if id == "" || strings.Contains(id, "/") || strings.Contains(id, ":") {
+		return nil, fmt.Errorf("%s: relying party id must be domain, got %q", op, id)
+	}
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("%s: at least one origin is required", op)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("%s: timeout must be positive", op)
+	}
+	if name == "" {
+		name = id
+	}
+
+	return &RelyingParty{
+		ID:      id,
+		Name:    name,
+		Origins: origins,
+		Timeout: timeout,
+	}, nil
+}
+
+// Bytes is binary field of WebAuthn JSON, encoded as unpadded base64url
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return err
+	}
+
+	*b = decoded
+	return nil
+}
+
+// User is account credential is registered for. Handle must not contain personal data,
+// authenticators return it on discoverable login to identify the account
+type User struct {
+	Handle      []byte
+	Name        string
+	DisplayName string
+}
+
+// Credential is public key credential created by authenticator, PublicKey is COSE_Key
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte
+	SignCount      uint32
+	AAGUID         []byte
+	Transports     []string
+	BackupEligible bool
+	BackedUp       bool
+}
+
+// CredentialDescriptor lists credential in options, so browser excludes or offers it
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         Bytes    `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+func NewCredentialDescriptor(id []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{Type: "public-key", ID: id, Transports: transports}
+}
+
+// NewChallenge returns random challenge, it must be stored until ceremony is finished
+func NewChallenge() []byte {
+	challenge := make([]byte, challengeSize)
+	_, _ = rand.Read(challenge) // never returns an error
+	return challenge
+}
+
+type creationOptions struct {
+	Challenge Bytes `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          Bytes  `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey        string `json:"residentKey"`
+		RequireResidentKey bool   `json:"requireResidentKey"`
+		UserVerification   string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type requestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// CreationOptions returns PublicKeyCredentialCreationOptions JSON for navigator.credentials.create,
+// browsers decode it with PublicKeyCredential.parseCreationOptionsFromJSON.
+// Discoverable credential is required, so the passkey can be used without typing email
+func (rp *RelyingParty) CreationOptions(challenge []byte, user User, exclude []CredentialDescriptor) ([]byte, error) {
+	var options creationOptions
+	options.Challenge = challenge
+	options.RP.ID = rp.ID
+	options.RP.Name = rp.Name
+	options.User.ID = user.Handle
+	options.User.Name = user.Name
+	options.User.DisplayName = user.DisplayName
+	for _, alg := range SupportedAlgorithms {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, credentialParameter{Type: "public-key", Alg: alg})
+	}
+	options.Timeout = rp.Timeout.Milliseconds()
+	options.ExcludeCredentials = nonNil(exclude)
+	options.AuthenticatorSelection.ResidentKey = "required"
+	options.AuthenticatorSelection.RequireResidentKey = true
+	options.AuthenticatorSelection.UserVerification = UserVerificationPreferred
+	options.Attestation = "none"
+
+	return json.Marshal(options)
+}
+
+// RequestOptions returns PublicKeyCredentialRequestOptions JSON for navigator.credentials.get,
+// empty allow lets user pick any discoverable credential of relying party
+func (rp *RelyingParty) RequestOptions(challenge []byte, allow []CredentialDescriptor, userVerification string) ([]byte, error) {
+	return json.Marshal(requestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		AllowCredentials: nonNil(allow),
+		UserVerification: userVerification,
+	})
+}
+
+// nonNil makes empty list marshal as [], some browsers reject null
+func nonNil(descriptors []CredentialDescriptor) []CredentialDescriptor {
+	if descriptors == nil {
+		return []CredentialDescriptor{}
+	}
+	return descriptors
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// Registration is parsed response of navigator.credentials.create, Challenge is what client signed
+type Registration struct {
+	Challenge []byte
+
+	rawID          []byte
+	transports     []string
+	clientData     clientData
+	attestationFmt string
+	authData       authenticatorData
+}
+
+type registrationJSON struct {
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes    `json:"clientDataJSON"`
+		AttestationObject Bytes    `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// ParseRegistration decodes PublicKeyCredential JSON returned by create().toJSON()
+func ParseRegistration(response []byte) (*Registration, error) {
+	var raw registrationJSON
+	if err := json.Unmarshal(response, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	if raw.Type != "public-key" {
+		return nil, fmt.Errorf("%w: credential type %q", ErrInvalidResponse, raw.Type)
+	}
+
+	cd, challenge, err := parseClientData(raw.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, rest, err := decodeCBOR(raw.Response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidResponse)
+	}
+	attestation, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidResponse)
+	}
+	attestationFmt, _ := attestation["fmt"].(string)
+	rawAuthData, _ := attestation["authData"].([]byte)
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedData == 0 {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrInvalidResponse)
+	}
+
+	return &Registration{
+		Challenge:      challenge,
+		rawID:          raw.RawID,
+		transports:     raw.Response.Transports,
+		clientData:     cd,
+		attestationFmt: attestationFmt,
+		authData:       authData,
+	}, nil
+}
+
+// VerifyRegistration checks registration answers challenge of this relying party and returns new credential.
+// Attestation statement is not verified, options ask for "none", so any authenticator model is accepted
+func (rp *RelyingParty) VerifyRegistration(registration *Registration, challenge []byte, requireUserVerification bool) (Credential, error) {
+	if err := rp.verifyClientData(registration.clientData, "webauthn.create", registration.Challenge, challenge); err != nil {
+		return Credential{}, err
+	}
+	if err := rp.verifyAuthenticatorData(registration.authData, requireUserVerification); err != nil {
+		return Credential{}, err
+	}
+	if registration.attestationFmt == "" {
+		return Credential{}, fmt.Errorf("%w: no attestation format", ErrInvalidResponse)
+	}
+
+	authData := registration.authData
+	if !bytes.Equal(registration.rawID, authData.credentialID) {
+		return Credential{}, fmt.Errorf("%w: credential id mismatch", ErrInvalidResponse)
+	}
+
+	key, err := parsePublicKey(authData.credentialPublicKey)
+	if err != nil {
+		return Credential{}, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	if !slices.Contains(SupportedAlgorithms, key.alg) {
+		return Credential{}, fmt.Errorf("%w: algorithm %d was not offered", ErrInvalidResponse, key.alg)
+	}
+
+	return Credential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.credentialPublicKey,
+		SignCount:      authData.signCount,
+		AAGUID:         authData.aaguid,
+		Transports:     registration.transports,
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+		BackedUp:       authData.flags&flagBackedUp != 0,
+	}, nil
+}
+
+// Assertion is parsed response of navigator.credentials.get. CredentialID and UserHandle
+// find stored credential, UserHandle is only present for discoverable credentials
+type Assertion struct {
+	CredentialID []byte
+	UserHandle   []byte
+	Challenge    []byte
+
+	clientData    clientData
+	rawClientData []byte
+	rawAuthData   []byte
+	authData      authenticatorData
+	signature     []byte
+}
+
+type assertionJSON struct {
+	RawID    Bytes  `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    Bytes `json:"clientDataJSON"`
+		AuthenticatorData Bytes `json:"authenticatorData"`
+		Signature         Bytes `json:"signature"`
+		UserHandle        Bytes `json:"userHandle"`
+	} `json:"response"`
+}
+
+// ParseAssertion decodes PublicKeyCredential JSON returned by get().toJSON()
+func ParseAssertion(response []byte) (*Assertion, error) {
+	var raw assertionJSON
+	if err := json.Unmarshal(response, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+	if raw.Type != "public-key" {
+		return nil, fmt.Errorf("%w: credential type %q", ErrInvalidResponse, raw.Type)
+	}
+	if len(raw.RawID) == 0 || len(raw.RawID) > maxCredentialIDLen {
+		return nil, fmt.Errorf("%w: invalid credential id", ErrInvalidResponse)
+	}
+
+	cd, challenge, err := parseClientData(raw.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	authData, err := parseAuthenticatorData(raw.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Assertion{
+		CredentialID:  raw.RawID,
+		UserHandle:    raw.Response.UserHandle,
+		Challenge:     challenge,
+		clientData:    cd,
+		rawClientData: raw.Response.ClientDataJSON,
+		rawAuthData:   raw.Response.AuthenticatorData,
+		authData:      authData,
+		signature:     raw.Response.Signature,
+	}, nil
+}
+
+// VerifyAssertion checks assertion is signed by credential for challenge of this relying party
+// and returns signature counter authenticator reported. Counter must grow past credential.SignCount,
+// unless both are 0, otherwise credential may be cloned and ErrSignCount is returned.
+// Caller still has to store the counter atomically, concurrent assertions may pass this check
+func (rp *RelyingParty) VerifyAssertion(assertion *Assertion, challenge []byte, credential Credential, requireUserVerification bool) (uint32, error) {
+	if !bytes.Equal(assertion.CredentialID, credential.ID) {
+		return 0, fmt.Errorf("%w: credential id mismatch", ErrInvalidResponse)
+	}
+	if err := rp.verifyClientData(assertion.clientData, "webauthn.get", assertion.Challenge, challenge); err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorData(assertion.authData, requireUserVerification); err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, fmt.Errorf("stored credential key: %w", err)
+	}
+
+	clientDataHash := sha256.Sum256(assertion.rawClientData)
+	signed := append(append([]byte(nil), assertion.rawAuthData...), clientDataHash[:]...)
+	if !key.verify(signed, assertion.signature) {
+		return 0, fmt.Errorf("%w: invalid signature", ErrInvalidResponse)
+	}
+
+	signCount := assertion.authData.signCount
+	if signCount <= credential.SignCount && (signCount != 0 || credential.SignCount != 0) {
+		return signCount, ErrSignCount
+	}
+
+	return signCount, nil
+}
+
+// BackedUp reports credential is synced to other devices of user at the moment of assertion
+func (a *Assertion) BackedUp() bool {
+	return a.authData.flags&flagBackedUp != 0
+}
+
+func parseClientData(raw []byte) (clientData, []byte, error) {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return clientData{}, nil, fmt.Errorf("%w: malformed client data", ErrInvalidResponse)
+	}
+
+	challenge, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(cd.Challenge, "="))
+	if err != nil || len(challenge) == 0 {
+		return clientData{}, nil, fmt.Errorf("%w: malformed challenge", ErrInvalidResponse)
+	}
+
+	return cd, challenge, nil
+}
+
+func (rp *RelyingParty) verifyClientData(cd clientData, ceremony string, signed []byte, challenge []byte) error {
+	if cd.Type != ceremony {
+		return fmt.Errorf("%w: client data type %q", ErrInvalidResponse, cd.Type)
+	}
+	if subtle.ConstantTimeCompare(signed, challenge) != 1 {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalidResponse)
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) {
+		return fmt.Errorf("%w: origin %q is not allowed", ErrInvalidResponse, cd.Origin)
+	}
+	// ceremony in cross-origin iframe could be started by other site
+	if cd.CrossOrigin {
+		return fmt.Errorf("%w: cross-origin ceremony", ErrInvalidResponse)
+	}
+	return nil
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(authData authenticatorData, requireUserVerification bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return fmt.Errorf("%w: relying party id mismatch", ErrInvalidResponse)
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return fmt.Errorf("%w: user is not present", ErrInvalidResponse)
+	}
+	if requireUserVerification && authData.flags&flagUserVerified == 0 {
+		return fmt.Errorf("%w: user is not verified", ErrInvalidResponse)
+	}
+	if authData.flags&flagBackedUp != 0 && authData.flags&flagBackupEligible == 0 {
+		return fmt.Errorf("%w: backed up credential is not backup eligible", ErrInvalidResponse)
+	}
+	return nil
+}
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	// attested credential data, registration only
+	aaguid              []byte
+	credentialID        []byte
+	credentialPublicKey []byte
+}
+
+// parseAuthenticatorData decodes rpIdHash(32) flags(1) signCount(4) [aaguid(16) idLen(2) id key] [extensions]
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	if len(data) < 37 {
+		return authenticatorData{}, fmt.Errorf("%w: authenticator data too short", ErrInvalidResponse)
+	}
+
+	authData := authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.flags&flagAttestedData != 0 {
+		if len(rest) < 18 {
+			return authenticatorData{}, fmt.Errorf("%w: attested credential data too short", ErrInvalidResponse)
+		}
+		authData.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > maxCredentialIDLen || idLen > len(rest) {
+			return authenticatorData{}, fmt.Errorf("%w: invalid credential id", ErrInvalidResponse)
+		}
+		authData.credentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		_, afterKey, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, fmt.Errorf("%w: credential public key: %v", ErrInvalidResponse, err)
+		}
+		authData.credentialPublicKey = rest[:len(rest)-len(afterKey)]
+		rest = afterKey
+	}
+
+	if authData.flags&flagExtensionData != 0 {
+		var err error
+		_, rest, err = decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, fmt.Errorf("%w: extensions: %v", ErrInvalidResponse, err)
+		}
+	}
+
+	if len(rest) != 0 {
+		return authenticatorData{}, fmt.Errorf("%w: trailing bytes in authenticator data", ErrInvalidResponse)
+	}
+
+	return authData, nil
+}
@@ -0,0 +1,523 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+// authenticator is software stand-in of platform authenticator holding one credential
+type authenticator struct {
+	rpID         string
+	credentialID []byte
+	alg          int64
+	ecdsaKey     *ecdsa.PrivateKey
+	ed25519Key   ed25519.PrivateKey
+	signCount    uint32
+	flags        byte
+}
+
+func newAuthenticator(t *testing.T, alg int64) *authenticator {
+	t.Helper()
+
+	a := &authenticator{
+		rpID:         testRPID,
+		credentialID: NewChallenge(),
+		alg:          alg,
+		flags:        flagUserPresent | flagUserVerified,
+	}
+
+	var err error
+	switch alg {
+	case AlgES256:
+		a.ecdsaKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, a.ed25519Key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	return a
+}
+
+// coseKey encodes public key as COSE_Key
+func (a *authenticator) coseKey() []byte {
+	if a.alg == AlgEdDSA {
+		return encodeCBOR(map[any]any{
+			coseKty: coseKtyOKP,
+			coseAlg: AlgEdDSA,
+			coseCrv: coseCrvEd25519,
+			coseX:   []byte(a.ed25519Key.Public().(ed25519.PublicKey)),
+		})
+	}
+
+	return encodeCBOR(map[any]any{
+		coseKty: coseKtyEC2,
+		coseAlg: AlgES256,
+		coseCrv: coseCrvP256,
+		coseX:   a.ecdsaKey.PublicKey.X.FillBytes(make([]byte, 32)),
+		coseY:   a.ecdsaKey.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+}
+
+// authData encodes authenticator data, attested credential data is included on registration
+func (a *authenticator) authData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	flags := a.flags
+
+	data := append([]byte(nil), rpIDHash[:]...)
+	if attested {
+		flags |= flagAttestedData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	if attested {
+		data = append(data, make([]byte, 16)...) // zero AAGUID, as with "none" attestation
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+
+	return data
+}
+
+func (a *authenticator) sign(data []byte) []byte {
+	if a.alg == AlgEdDSA {
+		return ed25519.Sign(a.ed25519Key, data)
+	}
+
+	digest := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, a.ecdsaKey, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
+
+// create returns what create().toJSON() of browser would for this credential
+func (a *authenticator) create(t *testing.T, clientData clientData) []byte {
+	t.Helper()
+
+	attestationObject := encodeCBOR(map[any]any{
+		"fmt":      "none",
+		"attStmt":  map[any]any{},
+		"authData": a.authData(true),
+	})
+
+	return marshalJSON(t, map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId": Bytes(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    Bytes(marshalJSON(t, clientData)),
+			"attestationObject": Bytes(attestationObject),
+			"transports":        []string{"internal"},
+		},
+	})
+}
+
+// get returns what get().toJSON() of browser would, counter is increased as real authenticators do
+func (a *authenticator) get(t *testing.T, clientData clientData, userHandle []byte) []byte {
+	t.Helper()
+
+	a.signCount++
+	authData := a.authData(false)
+	rawClientData := marshalJSON(t, clientData)
+	clientDataHash := sha256.Sum256(rawClientData)
+
+	return marshalJSON(t, map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId": Bytes(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    Bytes(rawClientData),
+			"authenticatorData": Bytes(authData),
+			"signature":         Bytes(a.sign(append(authData, clientDataHash[:]...))),
+			"userHandle":        Bytes(userHandle),
+		},
+	})
+}
+
+func newClientData(ceremony string, challenge []byte) clientData {
+	return clientData{
+		Type:      ceremony,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    testOrigin,
+	}
+}
+
+func newTestRelyingParty(t *testing.T) *RelyingParty {
+	t.Helper()
+
+	rp, err := NewRelyingParty(testRPID, "Example", []string{testOrigin}, time.Minute)
+	if err != nil {
+		t.Fatalf("NewRelyingParty() error = %v", err)
+	}
+	return rp
+}
+
+// register runs registration ceremony and returns credential relying party stores
+func register(t *testing.T, rp *RelyingParty, a *authenticator) Credential {
+	t.Helper()
+
+	challenge := NewChallenge()
+	registration, err := ParseRegistration(a.create(t, newClientData("webauthn.create", challenge)))
+	if err != nil {
+		t.Fatalf("ParseRegistration() error = %v", err)
+	}
+	credential, err := rp.VerifyRegistration(registration, challenge, true)
+	if err != nil {
+		t.Fatalf("VerifyRegistration() error = %v", err)
+	}
+	return credential
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	for _, alg := range []int64{AlgES256, AlgEdDSA} {
+		a := newAuthenticator(t, alg)
+		a.signCount = 3
+
+		credential := register(t, newTestRelyingParty(t), a)
+
+		if !bytes.Equal(credential.ID, a.credentialID) {
+			t.Errorf("alg %d: ID = %x, want %x", alg, credential.ID, a.credentialID)
+		}
+		if !bytes.Equal(credential.PublicKey, a.coseKey()) {
+			t.Errorf("alg %d: PublicKey = %x, want %x", alg, credential.PublicKey, a.coseKey())
+		}
+		if credential.SignCount != 3 {
+			t.Errorf("alg %d: SignCount = %d, want 3", alg, credential.SignCount)
+		}
+		if len(credential.Transports) != 1 || credential.Transports[0] != "internal" {
+			t.Errorf("alg %d: Transports = %v", alg, credential.Transports)
+		}
+	}
+}
+
+func TestVerifyRegistrationRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		requireUV bool
+		modify    func(a *authenticator, cd *clientData, challenge *[]byte)
+	}{
+		{"wrong origin", false, func(_ *authenticator, cd *clientData, _ *[]byte) { cd.Origin = "https://evil.example.org" }},
+		{"cross origin", false, func(_ *authenticator, cd *clientData, _ *[]byte) { cd.CrossOrigin = true }},
+		{"wrong rp id hash", false, func(a *authenticator, _ *clientData, _ *[]byte) { a.rpID = "evil.example.org" }},
+		{"wrong challenge", false, func(_ *authenticator, _ *clientData, challenge *[]byte) { *challenge = NewChallenge() }},
+		{"wrong ceremony", false, func(_ *authenticator, cd *clientData, _ *[]byte) { cd.Type = "webauthn.get" }},
+		{"user not present", false, func(a *authenticator, _ *clientData, _ *[]byte) { a.flags = flagUserVerified }},
+		{"user not verified", true, func(a *authenticator, _ *clientData, _ *[]byte) { a.flags = flagUserPresent }},
+		{"backed up not eligible", false, func(a *authenticator, _ *clientData, _ *[]byte) { a.flags |= flagBackedUp }},
+	}
+
+	rp := newTestRelyingParty(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(t, AlgES256)
+			challenge := NewChallenge()
+			cd := newClientData("webauthn.create", challenge)
+			expected := challenge
+			tt.modify(a, &cd, &expected)
+
+			registration, err := ParseRegistration(a.create(t, cd))
+			if err != nil {
+				t.Fatalf("ParseRegistration() error = %v", err)
+			}
+			if _, err := rp.VerifyRegistration(registration, expected, tt.requireUV); !errors.Is(err, ErrInvalidResponse) {
+				t.Errorf("VerifyRegistration() error = %v, want ErrInvalidResponse", err)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	for _, alg := range []int64{AlgES256, AlgEdDSA} {
+		rp := newTestRelyingParty(t)
+		a := newAuthenticator(t, alg)
+		credential := register(t, rp, a)
+		userHandle := []byte("user-handle")
+
+		challenge := NewChallenge()
+		assertion, err := ParseAssertion(a.get(t, newClientData("webauthn.get", challenge), userHandle))
+		if err != nil {
+			t.Fatalf("alg %d: ParseAssertion() error = %v", alg, err)
+		}
+		if !bytes.Equal(assertion.CredentialID, credential.ID) || !bytes.Equal(assertion.UserHandle, userHandle) {
+			t.Errorf("alg %d: CredentialID = %x, UserHandle = %q", alg, assertion.CredentialID, assertion.UserHandle)
+		}
+
+		signCount, err := rp.VerifyAssertion(assertion, challenge, credential, true)
+		if err != nil {
+			t.Fatalf("alg %d: VerifyAssertion() error = %v", alg, err)
+		}
+		if signCount != 1 {
+			t.Errorf("alg %d: sign count = %d, want 1", alg, signCount)
+		}
+	}
+}
+
+func TestVerifyAssertionRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		requireUV bool
+		modify    func(a *authenticator, cd *clientData, challenge *[]byte)
+	}{
+		{"wrong origin", false, func(_ *authenticator, cd *clientData, _ *[]byte) { cd.Origin = "https://evil.example.org" }},
+		{"wrong rp id hash", false, func(a *authenticator, _ *clientData, _ *[]byte) { a.rpID = "evil.example.org" }},
+		{"wrong challenge", false, func(_ *authenticator, _ *clientData, challenge *[]byte) { *challenge = NewChallenge() }},
+		{"wrong ceremony", false, func(_ *authenticator, cd *clientData, _ *[]byte) { cd.Type = "webauthn.create" }},
+		{"user not verified on primary login", true, func(a *authenticator, _ *clientData, _ *[]byte) { a.flags = flagUserPresent }},
+		{"other key", false, func(a *authenticator, _ *clientData, _ *[]byte) {
+			a.ecdsaKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}},
+	}
+
+	rp := newTestRelyingParty(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(t, AlgES256)
+			credential := register(t, rp, a)
+
+			challenge := NewChallenge()
+			cd := newClientData("webauthn.get", challenge)
+			expected := challenge
+			tt.modify(a, &cd, &expected)
+
+			assertion, err := ParseAssertion(a.get(t, cd, nil))
+			if err != nil {
+				t.Fatalf("ParseAssertion() error = %v", err)
+			}
+			if _, err := rp.VerifyAssertion(assertion, expected, credential, tt.requireUV); !errors.Is(err, ErrInvalidResponse) {
+				t.Errorf("VerifyAssertion() error = %v, want ErrInvalidResponse", err)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionUserVerification(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	a := newAuthenticator(t, AlgES256)
+	credential := register(t, rp, a)
+	// security key without PIN only proves presence, which is enough for second factor
+	a.flags = flagUserPresent
+
+	challenge := NewChallenge()
+	assertion, err := ParseAssertion(a.get(t, newClientData("webauthn.get", challenge), nil))
+	if err != nil {
+		t.Fatalf("ParseAssertion() error = %v", err)
+	}
+
+	if _, err := rp.VerifyAssertion(assertion, challenge, credential, true); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("VerifyAssertion(requireUV) error = %v, want ErrInvalidResponse", err)
+	}
+	if _, err := rp.VerifyAssertion(assertion, challenge, credential, false); err != nil {
+		t.Errorf("VerifyAssertion() error = %v", err)
+	}
+}
+
+func TestVerifyAssertionSignCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   uint32
+		received uint32
+		wantErr  error
+	}{
+		{"increased", 5, 6, nil},
+		{"both zero", 0, 0, nil},
+		{"equal", 5, 5, ErrSignCount},
+		{"decreased", 5, 4, ErrSignCount},
+		{"reset to zero", 5, 0, ErrSignCount},
+	}
+
+	rp := newTestRelyingParty(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthenticator(t, AlgEdDSA)
+			credential := register(t, rp, a)
+			credential.SignCount = tt.stored
+			// get increases counter before signing, 0 is reached by wrapping around
+			a.signCount = tt.received - 1
+
+			challenge := NewChallenge()
+			assertion, err := ParseAssertion(a.get(t, newClientData("webauthn.get", challenge), nil))
+			if err != nil {
+				t.Fatalf("ParseAssertion() error = %v", err)
+			}
+
+			signCount, err := rp.VerifyAssertion(assertion, challenge, credential, true)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyAssertion() error = %v, want %v", err, tt.wantErr)
+			}
+			if signCount != tt.received {
+				t.Errorf("sign count = %d, want %d", signCount, tt.received)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORMalformed(t *testing.T) {
+	deep := bytes.Repeat([]byte{0x81}, maxCBORDepth+2) // arrays of one item nested past the limit
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated argument", []byte{0x19, 0x01}},
+		{"truncated byte string", []byte{0x45, 0x01, 0x02}},
+		{"truncated text string", []byte{0x63, 'a'}},
+		{"truncated array", []byte{0x83, 0x01, 0x02}},
+		{"truncated map", []byte{0xa2, 0x01, 0x02, 0x03}},
+		{"map length beyond data", []byte{0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"indefinite length", []byte{0x5f, 0x41, 0x00, 0xff}},
+		{"unsupported map key", []byte{0xa1, 0x41, 0x00, 0x01}},
+		{"duplicate map key", []byte{0xa2, 0x01, 0x01, 0x01, 0x02}},
+		{"tag", []byte{0xc0, 0x01}},
+		{"float", []byte{0xf9, 0x00, 0x00}},
+		{"integer overflow", []byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"nested too deep", append(deep, 0x01)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCBOR(tt.data); !errors.Is(err, errCBOR) {
+				t.Errorf("decodeCBOR() error = %v, want errCBOR", err)
+			}
+		})
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	a := newAuthenticator(t, AlgES256)
+	challenge := NewChallenge()
+
+	var registration registrationJSON
+	if err := json.Unmarshal(a.create(t, newClientData("webauthn.create", challenge)), &registration); err != nil {
+		t.Fatalf("failed to decode registration: %v", err)
+	}
+	attestationObject := registration.Response.AttestationObject
+
+	for n := 0; n < len(attestationObject); n++ {
+		registration.Response.AttestationObject = attestationObject[:n]
+		if _, err := ParseRegistration(marshalJSON(t, registration)); !errors.Is(err, ErrInvalidResponse) {
+			t.Fatalf("ParseRegistration(%d of %d bytes) error = %v, want ErrInvalidResponse", n, len(attestationObject), err)
+		}
+	}
+
+	registration.Response.AttestationObject = append(attestationObject, 0x00)
+	if _, err := ParseRegistration(marshalJSON(t, registration)); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("ParseRegistration(trailing byte) error = %v, want ErrInvalidResponse", err)
+	}
+
+	var assertion assertionJSON
+	if err := json.Unmarshal(a.get(t, newClientData("webauthn.get", challenge), nil), &assertion); err != nil {
+		t.Fatalf("failed to decode assertion: %v", err)
+	}
+	authData := assertion.Response.AuthenticatorData
+
+	for n := 0; n < len(authData); n++ {
+		assertion.Response.AuthenticatorData = authData[:n]
+		if _, err := ParseAssertion(marshalJSON(t, assertion)); !errors.Is(err, ErrInvalidResponse) {
+			t.Fatalf("ParseAssertion(%d of %d bytes) error = %v, want ErrInvalidResponse", n, len(authData), err)
+		}
+	}
+
+	// extension flag set without extensions following
+	assertion.Response.AuthenticatorData = append([]byte(nil), authData...)
+	assertion.Response.AuthenticatorData[32] |= flagExtensionData
+	if _, err := ParseAssertion(marshalJSON(t, assertion)); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("ParseAssertion(missing extensions) error = %v, want ErrInvalidResponse", err)
+	}
+}
+
+func marshalJSON(t *testing.T, v any) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal json: %v", err)
+	}
+	return data
+}
+
+// encodeCBOR encodes the subset of CBOR decodeCBOR supports
+func encodeCBOR(v any) []byte {
+	switch v := v.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return encodeCBORHead(1, uint64(-1-v))
+		}
+		return encodeCBORHead(0, uint64(v))
+	case []byte:
+		return append(encodeCBORHead(2, uint64(len(v))), v...)
+	case string:
+		return append(encodeCBORHead(3, uint64(len(v))), v...)
+	case []any:
+		data := encodeCBORHead(4, uint64(len(v)))
+		for _, item := range v {
+			data = append(data, encodeCBOR(item)...)
+		}
+		return data
+	case map[any]any:
+		// CTAP2 canonical order, shorter encoded keys first, so equal maps encode to equal bytes
+		keys := make([][]byte, 0, len(v))
+		values := make(map[string]any, len(v))
+		for key, value := range v {
+			encoded := encodeCBOR(key)
+			keys = append(keys, encoded)
+			values[string(encoded)] = value
+		}
+		slices.SortFunc(keys, func(a, b []byte) int {
+			if len(a) != len(b) {
+				return len(a) - len(b)
+			}
+			return bytes.Compare(a, b)
+		})
+
+		data := encodeCBORHead(5, uint64(len(v)))
+		for _, key := range keys {
+			data = append(data, key...)
+			data = append(data, encodeCBOR(values[string(key)])...)
+		}
+		return data
+	default:
+		panic("unsupported cbor value")
+	}
+}
+
+func encodeCBORHead(major byte, arg uint64) []byte {
+	head := major << 5
+	switch {
+	case arg < 24:
+		return []byte{head | byte(arg)}
+	case arg <= 0xff:
+		return []byte{head | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{head | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{head | 26}, uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{head | 27}, arg)
+	}
+}
@@ -0,0 +1,220 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpServer is in-process stand-in of SMTP server accepting single connection
+type smtpServer struct {
+	listener net.Listener
+	// silent server accepts connection and never greets, as a hung server does
+	silent bool
+
+	done     chan struct{}
+	from     string
+	to       string
+	data     string
+	commands []string
+}
+
+func newSMTPServer(t *testing.T, silent bool) *smtpServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &smtpServer{listener: listener, silent: silent, done: make(chan struct{})}
+	go s.serve()
+
+	return s
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve() {
+	defer close(s.done)
+
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if s.silent {
+		// hold connection until client gives up
+		_, _ = conn.Read(make([]byte, 1))
+		return
+	}
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		s.commands = append(s.commands, command)
+
+		switch command {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			s.from = line
+			reply("250 OK")
+		case "RCPT":
+			s.to = line
+			reply("250 OK")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func newTestSMTPMailer(t *testing.T, port int, timeout time.Duration) *SMTPMailer {
+	t.Helper()
+
+	m, err := NewSMTPMailer(SMTPConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		TLS:     TLSNone,
+		From:    "SmartAPIForge <no-reply@example.com>",
+		Timeout: timeout,
+	})
+	if err != nil {
+		t.Fatalf("failed to create mailer: %v", err)
+	}
+
+	return m
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newSMTPServer(t, false)
+	m := newTestSMTPMailer(t, server.port(), 5*time.Second)
+
+	err := m.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Verify email",
+		Text:    "Open the link",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	<-server.done
+
+	if server.from != "MAIL FROM:<no-reply@example.com>" {
+		t.Errorf("MAIL = %q", server.from)
+	}
+	if server.to != "RCPT TO:<user@example.com>" {
+		t.Errorf("RCPT = %q", server.to)
+	}
+	if !strings.Contains(server.data, "Subject: Verify email\r\n") || !strings.Contains(server.data, "Open the link") {
+		t.Errorf("DATA = %q", server.data)
+	}
+	if last := server.commands[len(server.commands)-1]; last != "QUIT" {
+		t.Errorf("last command = %q, want QUIT", last)
+	}
+}
+
+func TestSMTPMailerSendInvalidRecipient(t *testing.T) {
+	// nothing listens on the port, Send must fail before dialing
+	m := newTestSMTPMailer(t, 1, 5*time.Second)
+
+	err := m.Send(context.Background(), Message{To: "user@example.com\r\nBcc: x@example.com", Subject: "s", Text: "t"})
+	if !errors.Is(err, ErrInvalidRecipient) {
+		t.Fatalf("Send() error = %v, want ErrInvalidRecipient", err)
+	}
+}
+
+func TestSMTPMailerSendTimeout(t *testing.T) {
+	server := newSMTPServer(t, true)
+	m := newTestSMTPMailer(t, server.port(), 200*time.Millisecond)
+
+	start := time.Now()
+	err := m.Send(context.Background(), Message{To: "user@example.com", Subject: "s", Text: "t"})
+	if err == nil {
+		t.Fatal("Send() error = nil, want timeout")
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Send() error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send() took %v, timeout is not applied", elapsed)
+	}
+}
+
+func TestSMTPMailerSendContextDeadline(t *testing.T) {
+	server := newSMTPServer(t, true)
+	// configured timeout is long, deadline of ctx wins
+	m := newTestSMTPMailer(t, server.port(), time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := m.Send(ctx, Message{To: "user@example.com", Subject: "s", Text: "t"}); err == nil {
+		t.Fatal("Send() error = nil, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send() took %v, ctx deadline is not applied", elapsed)
+	}
+}
+
+func TestNewSMTPMailerValidation(t *testing.T) {
+	valid := SMTPConfig{Host: "localhost", Port: 25, TLS: TLSNone, From: "no-reply@example.com", Timeout: time.Second}
+
+	tests := []struct {
+		name   string
+		modify func(c *SMTPConfig)
+	}{
+		{"unknown tls mode", func(c *SMTPConfig) { c.TLS = "ssl" }},
+		{"invalid from", func(c *SMTPConfig) { c.From = "not an address" }},
+		{"zero timeout", func(c *SMTPConfig) { c.Timeout = 0 }},
+	}
+
+	if _, err := NewSMTPMailer(valid); err != nil {
+		t.Fatalf("NewSMTPMailer(valid) error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+			if _, err := NewSMTPMailer(config); err == nil {
+				t.Error("NewSMTPMailer() error = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+var ErrTemplateNotFound = errors.New("email template not found")
+
+// messageTemplate is built from <locale>/<name>.subject.txt, <name>.txt and optional <name>.html files
+type messageTemplate struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// Templates renders messages in locale of recipient, falling back to default locale
+type Templates struct {
+	defaultLocale string
+	locales       map[string]map[string]*messageTemplate
+}
+
+// LoadTemplates parses embedded templates, files of dir (if set) override them by locale and name
+func LoadTemplates(dir string, defaultLocale string) (*Templates, error) {
+	const op = "mailer.LoadTemplates"
+
+	t := &Templates{
+		defaultLocale: normalizeLocale(defaultLocale),
+		locales:       make(map[string]map[string]*messageTemplate),
+	}
+
+	embeddedTemplates, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := t.load(embeddedTemplates); err != nil {
+		return nil, fmt.Errorf("%s: embedded: %w", op, err)
+	}
+
+	if dir != "" {
+		if err := t.load(os.DirFS(dir)); err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, dir, err)
+		}
+	}
+
+	if _, ok := t.locales[t.defaultLocale]; !ok {
+		return nil, fmt.Errorf("%s: no templates for default locale %q", op, t.defaultLocale)
+	}
+
+	return t, nil
+}
+
+func (t *Templates) load(fsys fs.FS) error {
+	localeDirs, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+
+	for _, localeDir := range localeDirs {
+		if !localeDir.IsDir() {
+			continue
+		}
+		locale := normalizeLocale(localeDir.Name())
+
+		names, err := fs.Glob(fsys, path.Join(localeDir.Name(), "*.subject.txt"))
+		if err != nil {
+			return err
+		}
+
+		for _, subjectFile := range names {
+			name := strings.TrimSuffix(path.Base(subjectFile), ".subject.txt")
+			tmpl, err := parseMessageTemplate(fsys, localeDir.Name(), name)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", locale, name, err)
+			}
+
+			if t.locales[locale] == nil {
+				t.locales[locale] = make(map[string]*messageTemplate)
+			}
+			t.locales[locale][name] = tmpl
+		}
+	}
+
+	return nil
+}
+
+func parseMessageTemplate(fsys fs.FS, dir string, name string) (*messageTemplate, error) {
+	base := path.Join(dir, name)
+
+	subject, err := texttemplate.ParseFS(fsys, base+".subject.txt")
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.ParseFS(fsys, base+".txt")
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &messageTemplate{subject: subject, text: text}
+
+	if _, err := fs.Stat(fsys, base+".html"); err == nil {
+		tmpl.html, err = htmltemplate.ParseFS(fsys, base+".html")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tmpl, nil
+}
+
+// Render executes template name in locale, "pt-BR" falls back to "pt" and then to default locale
+func (t *Templates) Render(locale string, name string, data any) (Message, error) {
+	tmpl, ok := t.lookup(locale, name)
+	if !ok {
+		return Message{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return Message{}, err
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return Message{}, err
+	}
+	if tmpl.html != nil {
+		if err := tmpl.html.Execute(&html, data); err != nil {
+			return Message{}, err
+		}
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+func (t *Templates) lookup(locale string, name string) (*messageTemplate, bool) {
+	locale = normalizeLocale(locale)
+	primary, _, _ := strings.Cut(locale, "-")
+
+	for _, candidate := range []string{locale, primary, t.defaultLocale} {
+		if tmpl, ok := t.locales[candidate][name]; ok {
+			return tmpl, true
+		}
+	}
+
+	return nil, false
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// TemplateMailer renders templates in locale from context and sends them via mailer
+type TemplateMailer struct {
+	mailer    Mailer
+	templates *Templates
+}
+
+func NewTemplateMailer(mailer Mailer, templates *Templates) *TemplateMailer {
+	return &TemplateMailer{
+		mailer:    mailer,
+		templates: templates,
+	}
+}
+
+func (m *TemplateMailer) SendTemplate(ctx context.Context, to string, name string, data any) error {
+	const op = "mailer.TemplateMailer.SendTemplate"
+
+	msg, err := m.templates.Render(LocaleFromContext(ctx), name, data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	msg.To = to
+
+	if err := m.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,249 @@
+package accessservice
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/sl"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+type Storage interface {
+	GetRoleChain(ctx context.Context, roleIDs []int64) ([]int64, error)
+	GetRolePermissions(ctx context.Context, roleIDs []int64) ([]string, error)
+	GetRoles(ctx context.Context) ([]models.Role, error)
+	SaveRole(ctx context.Context, role models.Role) (int64, error)
+	UpdateRole(ctx context.Context, role models.Role) error
+	DeleteRole(ctx context.Context, roleID int64) error
+	GetUserByUsername(ctx context.Context, username string) (models.User, error)
+	AddUserRole(ctx context.Context, userID int64, roleID int64) error
+	RemoveUserRole(ctx context.Context, userID int64, roleID int64) error
+}
+
+var (
+	ErrRoleCycle   = errors.New("role can not inherit from itself")
+	ErrBuiltinRole = errors.New("builtin role can not be deleted")
+)
+
+type AccessService struct {
+	log     *slog.Logger
+	storage Storage
+}
+
+func NewAccessService(
+	log *slog.Logger,
+	storage Storage,
+) *AccessService {
+	return &AccessService{
+		log:     log,
+		storage: storage,
+	}
+}
+
+// HasRole reports whether any of roles is requiredRole or inherits from it
+func (s *AccessService) HasRole(
+	ctx context.Context,
+	roleIDs []int64,
+	requiredRole int64,
+) (bool, error) {
+	const op = "access.HasRole"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Any("role_ids", roleIDs),
+	)
+
+	if len(roleIDs) == 0 {
+		return false, nil
+	}
+
+	chain, err := s.storage.GetRoleChain(ctx, roleIDs)
+	if err != nil {
+		log.Error("failed to get role chain", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return slices.Contains(chain, requiredRole), nil
+}
+
+// HasPermissions reports whether roles together hold every permission of allOf and at least one of anyOf.
+// Empty set is not checked
+func (s *AccessService) HasPermissions(
+	ctx context.Context,
+	roleIDs []int64,
+	anyOf []string,
+	allOf []string,
+) (bool, error) {
+	const op = "access.HasPermissions"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Any("role_ids", roleIDs),
+	)
+
+	granted, err := s.storage.GetRolePermissions(ctx, roleIDs)
+	if err != nil {
+		log.Error("failed to get role permissions", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, permission := range allOf {
+		if !slices.Contains(granted, permission) {
+			return false, nil
+		}
+	}
+
+	if len(anyOf) > 0 && !slices.ContainsFunc(anyOf, func(permission string) bool {
+		return slices.Contains(granted, permission)
+	}) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (s *AccessService) CreateRole(
+	ctx context.Context,
+	role models.Role,
+) (int64, error) {
+	const op = "access.CreateRole"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("name", role.Name),
+	)
+
+	id, err := s.storage.SaveRole(ctx, role)
+	if err != nil {
+		log.Error("failed to save role", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *AccessService) ListRoles(ctx context.Context) ([]models.Role, error) {
+	const op = "access.ListRoles"
+
+	log := s.log.With(slog.String("op", op))
+
+	roles, err := s.storage.GetRoles(ctx)
+	if err != nil {
+		log.Error("failed to get roles", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// UpdateRole replaces role name, parent and permissions. Parent can not be the role itself or its descendant
+func (s *AccessService) UpdateRole(
+	ctx context.Context,
+	role models.Role,
+) error {
+	const op = "access.UpdateRole"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("role_id", role.ID),
+	)
+
+	if role.ParentID != nil {
+		chain, err := s.storage.GetRoleChain(ctx, []int64{*role.ParentID})
+		if err != nil {
+			log.Error("failed to get role chain", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if slices.Contains(chain, role.ID) {
+			return fmt.Errorf("%s: %w", op, ErrRoleCycle)
+		}
+	}
+
+	if err := s.storage.UpdateRole(ctx, role); err != nil {
+		log.Error("failed to update role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *AccessService) DeleteRole(
+	ctx context.Context,
+	roleID int64,
+) error {
+	const op = "access.DeleteRole"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("role_id", roleID),
+	)
+
+	if roleID == models.RoleAdmin || roleID == models.RoleCustomer {
+		return fmt.Errorf("%s: %w", op, ErrBuiltinRole)
+	}
+
+	if err := s.storage.DeleteRole(ctx, roleID); err != nil {
+		log.Error("failed to delete role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AssignRole adds role to roles of user
+func (s *AccessService) AssignRole(
+	ctx context.Context,
+	username string,
+	roleID int64,
+) error {
+	const op = "access.AssignRole"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("username", username),
+		slog.Int64("role_id", roleID),
+	)
+
+	user, err := s.storage.GetUserByUsername(ctx, username)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.storage.AddUserRole(ctx, user.ID, roleID); err != nil {
+		log.Error("failed to assign role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeRole takes role away from user, other roles of the user are kept
+func (s *AccessService) RevokeRole(
+	ctx context.Context,
+	username string,
+	roleID int64,
+) error {
+	const op = "access.RevokeRole"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("username", username),
+		slog.Int64("role_id", roleID),
+	)
+
+	user, err := s.storage.GetUserByUsername(ctx, username)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.storage.RemoveUserRole(ctx, user.ID, roleID); err != nil {
+		log.Error("failed to revoke role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package authservice
+
+import (
+	"auth-service/internal/lib/sl"
+	"auth-service/internal/storage"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Kinds of attempts failures of which are limited per user across challenges
+const (
+	attemptKindMfa       = "mfa"
+	attemptKindLoginCode = "login_code"
+)
+
+// reserveAttempt counts attempt of user as failed until resetAttempts is called after success,
+// it fails with ErrTooManyAttempts once maxFailures are counted within window
+func (a *AuthService) reserveAttempt(
+	ctx context.Context,
+	log *slog.Logger,
+	userID int64,
+	kind string,
+	maxFailures int,
+	window time.Duration,
+) error {
+	if err := a.storage.ReserveAttempt(ctx, userID, kind, maxFailures, window); err != nil {
+		if errors.Is(err, storage.ErrTooManyAttempts) {
+			log.Warn("too many failed attempts", slog.String("kind", kind))
+			return ErrTooManyAttempts
+		}
+		log.Error("failed to reserve attempt", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// resetAttempts forgets failures of user after successful attempt. Failure only leaves the counter as is,
+// so it does not fail the attempt
+func (a *AuthService) resetAttempts(ctx context.Context, log *slog.Logger, userID int64, kind string) {
+	if err := a.storage.ResetAttempts(ctx, userID, kind); err != nil {
+		log.Error("failed to reset attempts", slog.String("kind", kind), sl.Err(err))
+	}
+}
@@ -0,0 +1,190 @@
+package authservice
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/secret"
+	"auth-service/internal/lib/sl"
+	"auth-service/internal/storage"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const loginCodeDigits = 6
+
+// loginCodeEmail is data of login_code template
+type loginCodeEmail struct {
+	Username  string
+	Code      string
+	Link      string
+	ExpiresAt time.Time
+}
+
+// RequestLoginCode mails one-time code and link logging in without password. Like RequestPasswordReset
+// it responds the same way for unknown emails and sends in background. Requests more often than
+// ResendInterval are ignored, every new code cancels the previous one
+func (a *AuthService) RequestLoginCode(
+	ctx context.Context,
+	email string,
+) error {
+	const op = "auth.RequestLoginCode"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	user, err := a.storage.GetUser(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Debug("user not found")
+			return nil
+		}
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go a.sendLoginCode(context.WithoutCancel(ctx), log, user)
+
+	return nil
+}
+
+func (a *AuthService) sendLoginCode(ctx context.Context, log *slog.Logger, user models.User) {
+	code := generateLoginCode()
+	token := secret.Generate(32)
+	expiresAt := time.Now().Add(a.loginCode.TTL)
+
+	err := a.storage.SaveLoginCode(ctx, models.LoginCode{
+		UserID:    user.ID,
+		CodeHash:  secret.Hash(code),
+		TokenHash: secret.Hash(token),
+		ExpiresAt: expiresAt,
+	}, a.loginCode.ResendInterval)
+	if err != nil {
+		if errors.Is(err, storage.ErrLoginCodeTooSoon) {
+			log.Debug("login code was sent recently")
+			return
+		}
+		log.Error("failed to save login code", sl.Err(err))
+		return
+	}
+
+	link, err := loginCodeLink(a.loginCode.URL, user.Email, token)
+	if err != nil {
+		log.Error("failed to build login link", sl.Err(err))
+		return
+	}
+
+	err = a.mailer.SendTemplate(ctx, user.Email, "login_code", loginCodeEmail{
+		Username:  user.Username,
+		Code:      code,
+		Link:      link,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Error("failed to send login code email", sl.Err(err))
+	}
+}
+
+// LoginWithCode logs in by code or link token from RequestLoginCode email. Result is the same as of Login,
+// so users with second factor still get MfaToken. Current code accepts at most MaxAttempts tries,
+// failures across codes are limited by MaxFailures, so requesting new codes does not give more guesses
+func (a *AuthService) LoginWithCode(
+	ctx context.Context,
+	email string,
+	code string,
+	client models.ClientInfo,
+) (LoginResult, error) {
+	const op = "auth.LoginWithCode"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	user, err := a.storage.GetUser(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Debug("user not found")
+			return LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidLoginCode)
+		}
+		log.Error("failed to get user", sl.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stored, err := a.storage.AttemptLoginCode(ctx, user.ID, a.loginCode.MaxAttempts)
+	if err != nil {
+		if errors.Is(err, storage.ErrLoginCodeNotFound) {
+			log.Debug("login code not found, expired or out of attempts")
+			return LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidLoginCode)
+		}
+		log.Error("failed to attempt login code", sl.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.reserveAttempt(ctx, log, user.ID, attemptKindLoginCode, a.loginCode.MaxFailures, a.loginCode.FailureWindow)
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	codeHash := secret.Hash(strings.TrimSpace(code))
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(stored.CodeHash)) != 1 &&
+		subtle.ConstantTimeCompare([]byte(codeHash), []byte(stored.TokenHash)) != 1 {
+		log.Warn("invalid login code", slog.Int("attempt", stored.Attempts))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidLoginCode)
+	}
+
+	if err := a.storage.ConsumeLoginCode(ctx, stored.ID); err != nil {
+		if errors.Is(err, storage.ErrLoginCodeNotFound) {
+			log.Warn("login code used concurrently")
+			return LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidLoginCode)
+		}
+		log.Error("failed to consume login code", sl.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.resetAttempts(ctx, log, user.ID, attemptKindLoginCode)
+
+	// storage verified email together with consuming the code, issued tokens must say so
+	user.EmailVerified = true
+
+	result, err := a.completeLogin(ctx, log, user, client)
+	if err != nil {
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// generateLoginCode returns uniformly random decimal code of loginCodeDigits digits
+func generateLoginCode() string {
+	limit := big.NewInt(1)
+	for i := 0; i < loginCodeDigits; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+
+	n, _ := rand.Int(rand.Reader, limit) // never returns an error
+	return fmt.Sprintf("%0*d", loginCodeDigits, n)
+}
+
+// loginCodeLink appends email and token to frontend page, it calls LoginWithCode with them
+func loginCodeLink(baseURL string, email string, token string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set("email", email)
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
@@ -0,0 +1,412 @@
+package authservice
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/secret"
+	"auth-service/internal/lib/sl"
+	"auth-service/internal/lib/totp"
+	"auth-service/internal/storage"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BeginTotpEnrollment generates authenticator secret for the access token owner.
+// Second factor is not required until ConfirmTotp proves the app was set up, calling again replaces pending secret
+func (a *AuthService) BeginTotpEnrollment(
+	ctx context.Context,
+	accessToken string,
+) (string, string, error) {
+	const op = "auth.BeginTotpEnrollment"
+
+	log := a.log.With(slog.String("op", op))
+
+	if a.secretCipher == nil {
+		return "", "", fmt.Errorf("%s: %w", op, ErrTotpDisabled)
+	}
+
+	payload, err := a.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", payload.Uid))
+
+	user, err := a.storage.GetUserByID(ctx, payload.Uid)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	totpSecret := totp.GenerateSecret()
+
+	encrypted, err := a.secretCipher.Encrypt([]byte(totpSecret), totpAssociatedData(user.ID))
+	if err != nil {
+		log.Error("failed to encrypt totp secret", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.SaveTotp(ctx, user.ID, encrypted); err != nil {
+		if errors.Is(err, storage.ErrTotpConfirmed) {
+			return "", "", fmt.Errorf("%s: %w", op, ErrMfaEnabled)
+		}
+		log.Error("failed to save totp", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enrollment started")
+
+	return totpSecret, totp.URI(a.mfa.Issuer, user.Email, totpSecret), nil
+}
+
+// ConfirmTotp enables second factor of the access token owner once code from authenticator app matches.
+// Returned recovery codes replace TOTP code if the app is lost, they are never shown again
+func (a *AuthService) ConfirmTotp(
+	ctx context.Context,
+	accessToken string,
+	code string,
+) ([]string, error) {
+	const op = "auth.ConfirmTotp"
+
+	log := a.log.With(slog.String("op", op))
+
+	payload, err := a.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", payload.Uid))
+
+	enrollment, err := a.storage.GetTotp(ctx, payload.Uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrTotpNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrMfaNotEnrolled)
+		}
+		log.Error("failed to get totp", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if enrollment.ConfirmedAt != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrMfaEnabled)
+	}
+
+	step, err := a.validateTotp(enrollment, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidMfaCode):
+			log.Warn("invalid totp code")
+		case errors.Is(err, ErrTotpDisabled):
+			log.Warn("totp code is not accepted, totp is disabled")
+		default:
+			log.Error("failed to validate totp code", sl.Err(err))
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.ConfirmTotp(ctx, payload.Uid, step); err != nil {
+		if errors.Is(err, storage.ErrTotpConfirmed) {
+			return nil, fmt.Errorf("%s: %w", op, ErrMfaEnabled)
+		}
+		log.Error("failed to confirm totp", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("totp enabled")
+
+	codes, err := a.replaceRecoveryCodes(ctx, payload.Uid)
+	if err != nil {
+		log.Error("failed to generate recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the access token owner, old ones stop working
+func (a *AuthService) RegenerateRecoveryCodes(
+	ctx context.Context,
+	accessToken string,
+) ([]string, error) {
+	const op = "auth.RegenerateRecoveryCodes"
+
+	log := a.log.With(slog.String("op", op))
+
+	payload, err := a.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", payload.Uid))
+
+	enrollment, err := a.storage.GetTotp(ctx, payload.Uid)
+	if err != nil && !errors.Is(err, storage.ErrTotpNotFound) {
+		log.Error("failed to get totp", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err != nil || enrollment.ConfirmedAt == nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrMfaNotEnrolled)
+	}
+
+	codes, err := a.replaceRecoveryCodes(ctx, payload.Uid)
+	if err != nil {
+		log.Error("failed to generate recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("recovery codes regenerated")
+
+	return codes, nil
+}
+
+// CompleteMfaLogin exchanges challenge token from Login and second factor code for the token pair.
+// Code is either current TOTP code or one of recovery codes, failed codes are limited per user across challenges
+func (a *AuthService) CompleteMfaLogin(
+	ctx context.Context,
+	mfaToken string,
+	code string,
+	client models.ClientInfo,
+) (string, string, error) {
+	const op = "auth.CompleteMfaLogin"
+
+	log := a.log.With(slog.String("op", op))
+
+	challenge, err := a.storage.AttemptMfaChallenge(ctx, secret.Hash(mfaToken), a.mfa.MaxAttempts)
+	if err != nil {
+		if errors.Is(err, storage.ErrMfaChallengeNotFound) {
+			log.Debug("mfa challenge not found, expired or out of attempts")
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidMfaToken)
+		}
+		log.Error("failed to attempt mfa challenge", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", challenge.UserID))
+
+	if err := a.reserveAttempt(ctx, log, challenge.UserID, attemptKindMfa, a.mfa.MaxFailures, a.mfa.FailureWindow); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !isTotpCode(code) {
+		if err := a.useRecoveryCode(ctx, log, challenge.UserID, code); err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+		return a.finishMfaLogin(ctx, log, op, challenge, client)
+	}
+
+	enrollment, err := a.storage.GetTotp(ctx, challenge.UserID)
+	if err != nil && !errors.Is(err, storage.ErrTotpNotFound) {
+		log.Error("failed to get totp", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	// users having passkeys only are challenged too, they can not answer with TOTP code
+	if err != nil || enrollment.ConfirmedAt == nil {
+		log.Warn("totp code of user without totp")
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidMfaCode)
+	}
+
+	step, err := a.validateTotp(enrollment, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidMfaCode):
+			log.Warn("invalid totp code", slog.Int("attempt", challenge.Attempts))
+		case errors.Is(err, ErrTotpDisabled):
+			log.Warn("totp code is not accepted, totp is disabled")
+		default:
+			log.Error("failed to validate totp code", sl.Err(err))
+		}
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.storage.UseTotpStep(ctx, challenge.UserID, step); err != nil {
+		if errors.Is(err, storage.ErrTotpStepUsed) {
+			log.Warn("totp code replayed")
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidMfaCode)
+		}
+		log.Error("failed to use totp step", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a.finishMfaLogin(ctx, log, op, challenge, client)
+}
+
+// finishMfaLogin consumes answered challenge, forgets failed attempts and starts session of its user
+func (a *AuthService) finishMfaLogin(
+	ctx context.Context,
+	log *slog.Logger,
+	op string,
+	challenge models.MfaChallenge,
+	client models.ClientInfo,
+) (string, string, error) {
+	if err := a.storage.ConsumeMfaChallenge(ctx, challenge.ID); err != nil {
+		if errors.Is(err, storage.ErrMfaChallengeNotFound) {
+			log.Warn("mfa challenge used concurrently")
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidMfaToken)
+		}
+		log.Error("failed to consume mfa challenge", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.resetAttempts(ctx, log, challenge.UserID, attemptKindMfa)
+
+	user, err := a.storage.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	accessToken, refreshToken, err := a.startSession(ctx, user, client)
+	if err != nil {
+		log.Error("failed to start session", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("mfa login completed")
+
+	return accessToken, refreshToken, nil
+}
+
+// useRecoveryCode consumes recovery code and notifies about it, so the owner learns if it was not them
+func (a *AuthService) useRecoveryCode(ctx context.Context, log *slog.Logger, userID int64, code string) error {
+	remaining, err := a.storage.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+	if err != nil {
+		if errors.Is(err, storage.ErrRecoveryCodeNotFound) {
+			log.Warn("invalid recovery code")
+			return ErrInvalidMfaCode
+		}
+		log.Error("failed to use recovery code", sl.Err(err))
+		return err
+	}
+
+	log.Info("recovery code used", slog.Int("remaining", remaining))
+
+	user, err := a.storage.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return err
+	}
+
+	// code is already consumed, so failed notification does not fail the login
+	nativeRecoveryCodeUsed := map[string]interface{}{
+		"username":        user.Username,
+		"email":           user.Email,
+		"used_at":         time.Now().UTC().Format(time.RFC3339),
+		"remaining_codes": remaining,
+	}
+	if err := a.kafkaProducer.ProduceRecoveryCodeUsed(user.Email, nativeRecoveryCodeUsed); err != nil {
+		log.Error("failed to produce recovery code used event", sl.Err(err))
+	}
+
+	return nil
+}
+
+// replaceRecoveryCodes generates new recovery codes of user, only their hashes are stored
+func (a *AuthService) replaceRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		codes[i] = generateRecoveryCode()
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	if err := a.storage.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// startMfaChallenge issues single-use challenge token if user has second factor, empty token otherwise
+func (a *AuthService) startMfaChallenge(ctx context.Context, user models.User) (string, error) {
+	required, err := a.hasSecondFactor(ctx, user.ID)
+	if err != nil || !required {
+		return "", err
+	}
+
+	token := secret.Generate(32)
+
+	err = a.storage.SaveMfaChallenge(ctx, models.MfaChallenge{
+		UserID:    user.ID,
+		TokenHash: secret.Hash(token),
+		ExpiresAt: time.Now().Add(a.mfa.ChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// hasSecondFactor reports whether user has confirmed TOTP or at least one passkey
+func (a *AuthService) hasSecondFactor(ctx context.Context, userID int64) (bool, error) {
+	enrollment, err := a.storage.GetTotp(ctx, userID)
+	if err != nil && !errors.Is(err, storage.ErrTotpNotFound) {
+		return false, err
+	}
+	if err == nil && enrollment.ConfirmedAt != nil {
+		return true, nil
+	}
+
+	credentials, err := a.storage.GetWebauthnCredentials(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return len(credentials) > 0, nil
+}
+
+// validateTotp returns time step code of enrollment matched, replays are checked by storage.
+// Without secret key no code is accepted, recovery codes and passkeys still complete login
+func (a *AuthService) validateTotp(enrollment models.Totp, code string) (int64, error) {
+	if a.secretCipher == nil {
+		return 0, ErrTotpDisabled
+	}
+
+	plain, err := a.secretCipher.Decrypt(enrollment.Secret, totpAssociatedData(enrollment.UserID))
+	if err != nil {
+		return 0, fmt.Errorf("decrypt totp secret: %w", err)
+	}
+
+	step, ok := totp.Validate(string(plain), code, time.Now())
+	if !ok || step <= enrollment.LastUsedStep {
+		return 0, ErrInvalidMfaCode
+	}
+
+	return step, nil
+}
+
+func totpAssociatedData(userID int64) []byte {
+	return []byte("totp:" + strconv.FormatInt(userID, 10))
+}
+
+const recoveryCodesCount = 10
+
+// generateRecoveryCode returns 80 bits code like "k3v7-q2xa-vmfp-w4ze", entropy is high enough for fast hash
+func generateRecoveryCode() string {
+	b := make([]byte, 10)
+	_, _ = rand.Read(b) // never returns an error
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+}
+
+// hashRecoveryCode ignores case, spaces and dashes users may type differently
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return secret.Hash(normalized)
+}
+
+func isTotpCode(code string) bool {
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,460 @@
+package authservice
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/jwt"
+	"auth-service/internal/lib/secret"
+	"auth-service/internal/lib/sl"
+	"auth-service/internal/storage"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	responseTypeCode    = "code"
+	codeChallengeS256   = "S256"
+	minCodeVerifierSize = 43
+	maxCodeVerifierSize = 128
+)
+
+// OAuthTokens is token endpoint response, Scope is what user actually granted to client.
+// IDToken is issued by authorization_code grant with openid scope
+type OAuthTokens struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	Scope        string
+	ExpiresIn    time.Duration
+}
+
+// ValidateAuthorizationRequest checks request before user is sent to log in. Redirect URI must exactly match
+// registered one and PKCE with S256 is mandatory. ErrInvalidClient and ErrInvalidRedirectURI must not be
+// reported to redirect URI, other errors are. Omitted scope means all registered scopes of the client,
+// OpenID Connect scopes are allowed for every client
+func (a *AuthService) ValidateAuthorizationRequest(
+	ctx context.Context,
+	req models.AuthorizationRequest,
+) (models.OAuthClient, []string, error) {
+	const op = "auth.ValidateAuthorizationRequest"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_id", req.ClientID),
+	)
+
+	client, err := a.storage.GetOAuthClient(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrOAuthClientNotFound) {
+			log.Debug("oauth client not found")
+			return models.OAuthClient{}, nil, fmt.Errorf("%s: %w", op, ErrInvalidClient)
+		}
+		log.Error("failed to get oauth client", sl.Err(err))
+		return models.OAuthClient{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		log.Warn("redirect uri is not registered", slog.String("redirect_uri", req.RedirectURI))
+		return models.OAuthClient{}, nil, fmt.Errorf("%s: %w", op, ErrInvalidRedirectURI)
+	}
+
+	if req.ResponseType != responseTypeCode {
+		return models.OAuthClient{}, nil, fmt.Errorf("%s: %w", op, ErrUnsupportedResponseType)
+	}
+
+	// code challenge is base64url of SHA-256, so it has the same alphabet and length as a verifier
+	if req.CodeChallengeMethod != codeChallengeS256 || !validCodeVerifier(req.CodeChallenge) {
+		log.Debug("invalid pkce code challenge")
+		return models.OAuthClient{}, nil, fmt.Errorf("%s: %w", op, ErrInvalidOAuthRequest)
+	}
+
+	scope := req.Scope
+	if strings.TrimSpace(scope) == "" {
+		scope = strings.Join(client.Scopes, " ")
+	}
+	scopes, err := requestedScopes(scope, append(slices.Clone(client.Scopes), models.OIDCScopes...))
+	if err != nil {
+		log.Debug("scope is not allowed for client", slog.String("scope", req.Scope))
+		return models.OAuthClient{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return client, scopes, nil
+}
+
+// GetAuthorizationPrompt describes request to user logged in with accessToken,
+// consent is not required when user has already granted all requested scopes
+func (a *AuthService) GetAuthorizationPrompt(
+	ctx context.Context,
+	accessToken string,
+	req models.AuthorizationRequest,
+) (models.AuthorizationPrompt, error) {
+	const op = "auth.GetAuthorizationPrompt"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_id", req.ClientID),
+	)
+
+	claims, err := a.parseConsentToken(ctx, accessToken)
+	if err != nil {
+		return models.AuthorizationPrompt{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	client, scopes, err := a.ValidateAuthorizationRequest(ctx, req)
+	if err != nil {
+		return models.AuthorizationPrompt{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	prompt := models.AuthorizationPrompt{
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		Scopes:     scopes,
+	}
+	if client.FirstParty {
+		return prompt, nil
+	}
+
+	granted, err := a.storage.GetOAuthConsent(ctx, claims.Uid, client.ID)
+	if err != nil && !errors.Is(err, storage.ErrOAuthConsentNotFound) {
+		log.Error("failed to get oauth consent", sl.Err(err))
+		return models.AuthorizationPrompt{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			prompt.ConsentRequired = true
+			break
+		}
+	}
+
+	return prompt, nil
+}
+
+// Authorize records decision of user logged in with accessToken and returns authorization code
+// for the client. ErrAccessDenied is returned when user declined the request
+func (a *AuthService) Authorize(
+	ctx context.Context,
+	accessToken string,
+	req models.AuthorizationRequest,
+	approved bool,
+) (string, error) {
+	const op = "auth.Authorize"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_id", req.ClientID),
+	)
+
+	claims, err := a.parseConsentToken(ctx, accessToken)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	client, scopes, err := a.ValidateAuthorizationRequest(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", claims.Uid))
+
+	if !approved {
+		log.Info("user denied authorization")
+		return "", fmt.Errorf("%s: %w", op, ErrAccessDenied)
+	}
+
+	if !client.FirstParty {
+		if err := a.storage.SaveOAuthConsent(ctx, claims.Uid, client.ID, scopes); err != nil {
+			log.Error("failed to save oauth consent", sl.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// ID token tells client when user logged in, that is when the session of consent token started
+	var authTime *time.Time
+	if claims.SessionID != "" {
+		session, err := a.storage.GetSession(ctx, claims.SessionID)
+		if err != nil {
+			if errors.Is(err, storage.ErrSessionNotFound) {
+				log.Warn("session of consent token not found", slog.String("session_id", claims.SessionID))
+				return "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+			}
+			log.Error("failed to get session", sl.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		authTime = &session.CreatedAt
+	}
+
+	code := secret.Generate(32)
+	err = a.storage.SaveOAuthCode(ctx, models.OAuthCode{
+		CodeHash:      secret.Hash(code),
+		ClientID:      client.ID,
+		UserID:        claims.Uid,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(a.oauth.CodeTTL),
+		Nonce:         req.Nonce,
+		AuthTime:      authTime,
+	})
+	if err != nil {
+		log.Error("failed to save authorization code", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("authorization code issued")
+
+	return code, nil
+}
+
+// ExchangeAuthorizationCode is authorization_code grant of token endpoint. Code is bound to client,
+// redirect URI and PKCE challenge. Replayed code revokes the session started with it.
+// ID token is issued when openid scope was granted
+func (a *AuthService) ExchangeAuthorizationCode(
+	ctx context.Context,
+	clientID string,
+	clientSecret string,
+	code string,
+	redirectURI string,
+	codeVerifier string,
+	clientInfo models.ClientInfo,
+) (OAuthTokens, error) {
+	const op = "auth.ExchangeAuthorizationCode"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_id", clientID),
+	)
+
+	client, err := a.authenticateClient(ctx, log, clientID, clientSecret)
+	if err != nil {
+		return OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stored, err := a.storage.UseOAuthCode(ctx, secret.Hash(code), clientInfo)
+	if err != nil {
+		if errors.Is(err, storage.ErrOAuthCodeUsed) {
+			log.Warn("authorization code reuse detected", slog.Int64("uid", stored.UserID))
+			if stored.SessionID != nil {
+				if err := a.storage.RevokeSession(ctx, *stored.SessionID); err != nil {
+					log.Error("failed to revoke session", sl.Err(err))
+					return OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+				}
+			}
+			return OAuthTokens{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+		}
+		if errors.Is(err, storage.ErrOAuthCodeNotFound) {
+			log.Debug("authorization code not found or expired")
+			return OAuthTokens{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+		}
+		log.Error("failed to use authorization code", sl.Err(err))
+		return OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessionID := *stored.SessionID
+
+	if stored.ClientID != client.ID || stored.RedirectURI != redirectURI {
+		log.Warn("authorization code is issued for another client or redirect uri")
+		return OAuthTokens{}, fmt.Errorf("%s: %w", op, a.rejectOAuthCode(ctx, log, sessionID))
+	}
+
+	if !validCodeVerifier(codeVerifier) ||
+		subtle.ConstantTimeCompare([]byte(codeChallenge(codeVerifier)), []byte(stored.CodeChallenge)) != 1 {
+		log.Warn("pkce code verifier mismatch")
+		return OAuthTokens{}, fmt.Errorf("%s: %w", op, a.rejectOAuthCode(ctx, log, sessionID))
+	}
+
+	user, err := a.storage.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	accessToken, refreshToken, err := a.issueTokens(ctx, user, sessionID, client.ID, stored.Scope)
+	if err != nil {
+		log.Error("failed to issue tokens", sl.Err(err))
+		return OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var idToken string
+	if scopes := strings.Fields(stored.Scope); slices.Contains(scopes, models.ScopeOpenID) {
+		var authTime time.Time
+		if stored.AuthTime != nil {
+			authTime = *stored.AuthTime
+		}
+		idToken, err = jwt.NewIDToken(models.NewUserInfo(user, scopes), a.accessTokenTTL, client.ID, stored.Nonce, authTime)
+		if err != nil {
+			log.Error("failed to issue id token", sl.Err(err))
+			return OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("authorization code exchanged", slog.Int64("uid", user.ID))
+
+	return OAuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		IDToken:      idToken,
+		Scope:        stored.Scope,
+		ExpiresIn:    a.accessTokenTTL,
+	}, nil
+}
+
+// rejectOAuthCode revokes session started by using the code, as it is not exchanged for tokens
+func (a *AuthService) rejectOAuthCode(ctx context.Context, log *slog.Logger, sessionID string) error {
+	if err := a.storage.RevokeSession(ctx, sessionID); err != nil {
+		log.Error("failed to revoke session", sl.Err(err))
+		return err
+	}
+	return ErrInvalidGrant
+}
+
+// RefreshOAuthToken is refresh_token grant of token endpoint, token must be issued to the same client.
+// Requested scope must be within granted one, tokens keep the granted scope
+func (a *AuthService) RefreshOAuthToken(
+	ctx context.Context,
+	clientID string,
+	clientSecret string,
+	refreshToken string,
+	scope string,
+) (OAuthTokens, error) {
+	const op = "auth.RefreshOAuthToken"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("client_id", clientID),
+	)
+
+	client, err := a.authenticateClient(ctx, log, clientID, clientSecret)
+	if err != nil {
+		return OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	payload, err := jwt.ParseToken(refreshToken, jwt.TokenTypeRefresh)
+	if err != nil {
+		log.Debug("failed to parse refresh token", sl.Err(err))
+		return OAuthTokens{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+	}
+	if payload.ClientID != client.ID || payload.Scope == "" {
+		log.Warn("refresh token is issued to another client")
+		return OAuthTokens{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+	}
+
+	if scope != "" {
+		if _, err := requestedScopes(scope, strings.Fields(payload.Scope)); err != nil {
+			log.Debug("scope exceeds granted one", slog.String("scope", scope))
+			return OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	accessToken, newRefreshToken, err := a.rotateRefreshToken(ctx, log, refreshToken, payload)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTokenReused) {
+			return OAuthTokens{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+		}
+		return OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return OAuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		Scope:        payload.Scope,
+		ExpiresIn:    a.accessTokenTTL,
+	}, nil
+}
+
+// authenticateClient checks secret of confidential client, public clients must not send one
+func (a *AuthService) authenticateClient(
+	ctx context.Context,
+	log *slog.Logger,
+	clientID string,
+	clientSecret string,
+) (models.OAuthClient, error) {
+	client, err := a.storage.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrOAuthClientNotFound) {
+			log.Debug("oauth client not found")
+			return models.OAuthClient{}, ErrInvalidClient
+		}
+		log.Error("failed to get oauth client", sl.Err(err))
+		return models.OAuthClient{}, err
+	}
+
+	if client.Public() {
+		if clientSecret != "" {
+			log.Warn("public client sent secret")
+			return models.OAuthClient{}, ErrInvalidClient
+		}
+		return client, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(secret.Hash(clientSecret)), []byte(client.SecretHash)) != 1 {
+		log.Warn("invalid client secret")
+		return models.OAuthClient{}, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// parseConsentToken accepts tokens of direct login only, OAuth clients can not approve requests for user
+func (a *AuthService) parseConsentToken(ctx context.Context, accessToken string) (*jwt.Claims, error) {
+	claims, err := a.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Scope != "" {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// requestedScopes splits space separated scope removing duplicates, every scope must be allowed
+func requestedScopes(scope string, allowed []string) ([]string, error) {
+	requested := strings.Fields(scope)
+
+	scopes := make([]string, 0, len(requested))
+	for _, s := range requested {
+		if !slices.Contains(allowed, s) {
+			return nil, ErrInvalidScope
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	return scopes, nil
+}
+
+// validCodeVerifier checks RFC 7636 section 4.1 length and unreserved characters
+func validCodeVerifier(verifier string) bool {
+	if len(verifier) < minCodeVerifierSize || len(verifier) > maxCodeVerifierSize {
+		return false
+	}
+
+	for _, c := range verifier {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// codeChallenge is S256 transformation of verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
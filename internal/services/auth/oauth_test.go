@@ -0,0 +1,169 @@
+package authservice
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// RFC 7636 appendix B example
+const (
+	rfc7636Verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	rfc7636Challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+// clientStorage serves OAuth clients only, other Storage methods must not be called
+type clientStorage struct {
+	Storage
+	clients map[string]models.OAuthClient
+}
+
+func (s clientStorage) GetOAuthClient(_ context.Context, clientID string) (models.OAuthClient, error) {
+	client, ok := s.clients[clientID]
+	if !ok {
+		return models.OAuthClient{}, storage.ErrOAuthClientNotFound
+	}
+	return client, nil
+}
+
+func TestCodeChallenge(t *testing.T) {
+	if !validCodeVerifier(rfc7636Verifier) {
+		t.Errorf("validCodeVerifier(%q) = false", rfc7636Verifier)
+	}
+	if got := codeChallenge(rfc7636Verifier); got != rfc7636Challenge {
+		t.Errorf("codeChallenge() = %q, want %q", got, rfc7636Challenge)
+	}
+}
+
+func TestValidCodeVerifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		verifier string
+		want     bool
+	}{
+		{"shortest", strings.Repeat("a", minCodeVerifierSize), true},
+		{"longest", strings.Repeat("a", maxCodeVerifierSize), true},
+		{"unreserved characters", "AZaz09-._~" + strings.Repeat("x", minCodeVerifierSize), true},
+		{"too short", strings.Repeat("a", minCodeVerifierSize-1), false},
+		{"too long", strings.Repeat("a", maxCodeVerifierSize+1), false},
+		{"empty", "", false},
+		{"base64 padding", rfc7636Verifier + "=", false},
+		{"plus sign", strings.Replace(rfc7636Verifier, "-", "+", 1), false},
+		{"space", strings.Replace(rfc7636Verifier, "-", " ", 1), false},
+		{"non ascii", strings.Repeat("ä", minCodeVerifierSize), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validCodeVerifier(tt.verifier); got != tt.want {
+				t.Errorf("validCodeVerifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestedScopes(t *testing.T) {
+	allowed := []string{"projects:read", "projects:write", models.ScopeOpenID}
+
+	tests := []struct {
+		name    string
+		scope   string
+		want    []string
+		wantErr bool
+	}{
+		{"all allowed", "projects:read projects:write", []string{"projects:read", "projects:write"}, false},
+		{"narrowed", "projects:read", []string{"projects:read"}, false},
+		{"duplicates and extra spaces", " projects:read  openid projects:read ", []string{"projects:read", models.ScopeOpenID}, false},
+		{"not allowed", "projects:read users:delete", nil, true},
+		{"prefix of allowed", "projects", nil, true},
+		{"case differs", "Projects:read", nil, true},
+		{"empty", "   ", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := requestedScopes(tt.scope, allowed)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidScope) {
+					t.Errorf("requestedScopes() error = %v, want ErrInvalidScope", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("requestedScopes() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("requestedScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAuthorizationRequest(t *testing.T) {
+	a := &AuthService{
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		storage: clientStorage{clients: map[string]models.OAuthClient{
+			"app": {
+				ID:           "app",
+				RedirectURIs: []string{"https://app.example.com/callback"},
+				Scopes:       []string{"projects:read", "projects:write"},
+			},
+		}},
+	}
+
+	valid := models.AuthorizationRequest{
+		ResponseType:        responseTypeCode,
+		ClientID:            "app",
+		RedirectURI:         "https://app.example.com/callback",
+		CodeChallenge:       rfc7636Challenge,
+		CodeChallengeMethod: codeChallengeS256,
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(req *models.AuthorizationRequest)
+		want    []string
+		wantErr error
+	}{
+		{"omitted scope grants registered ones", func(*models.AuthorizationRequest) {}, []string{"projects:read", "projects:write"}, nil},
+		{"narrowed scope", func(req *models.AuthorizationRequest) { req.Scope = "projects:read" }, []string{"projects:read"}, nil},
+		{"openid scope", func(req *models.AuthorizationRequest) { req.Scope = "openid email" }, []string{"openid", "email"}, nil},
+		{"unregistered scope", func(req *models.AuthorizationRequest) { req.Scope = "projects:read users:delete" }, nil, ErrInvalidScope},
+		{"unknown client", func(req *models.AuthorizationRequest) { req.ClientID = "other" }, nil, ErrInvalidClient},
+		{"redirect uri with other path", func(req *models.AuthorizationRequest) { req.RedirectURI += "/../evil" }, nil, ErrInvalidRedirectURI},
+		{"redirect uri with query", func(req *models.AuthorizationRequest) { req.RedirectURI += "?next=https://evil.example" }, nil, ErrInvalidRedirectURI},
+		{"redirect uri with trailing slash", func(req *models.AuthorizationRequest) { req.RedirectURI += "/" }, nil, ErrInvalidRedirectURI},
+		{"redirect uri with other scheme", func(req *models.AuthorizationRequest) { req.RedirectURI = "http://app.example.com/callback" }, nil, ErrInvalidRedirectURI},
+		{"redirect uri with other case", func(req *models.AuthorizationRequest) { req.RedirectURI = "https://APP.example.com/callback" }, nil, ErrInvalidRedirectURI},
+		{"implicit flow", func(req *models.AuthorizationRequest) { req.ResponseType = "token" }, nil, ErrUnsupportedResponseType},
+		{"plain pkce", func(req *models.AuthorizationRequest) { req.CodeChallengeMethod = "plain" }, nil, ErrInvalidOAuthRequest},
+		{"missing pkce", func(req *models.AuthorizationRequest) { req.CodeChallenge, req.CodeChallengeMethod = "", "" }, nil, ErrInvalidOAuthRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.modify(&req)
+
+			client, scopes, err := a.ValidateAuthorizationRequest(context.Background(), req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateAuthorizationRequest() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if client.ID != "app" {
+				t.Errorf("ValidateAuthorizationRequest() client = %q, want app", client.ID)
+			}
+			if !slices.Equal(scopes, tt.want) {
+				t.Errorf("ValidateAuthorizationRequest() scopes = %v, want %v", scopes, tt.want)
+			}
+		})
+	}
+}
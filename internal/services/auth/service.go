@@ -4,12 +4,15 @@ import (
 	"auth-service/internal/domain/models"
 	"auth-service/internal/kafka"
 	"auth-service/internal/lib/jwt"
+	"auth-service/internal/lib/password"
+	"auth-service/internal/lib/secret"
 	"auth-service/internal/lib/sl"
+	"auth-service/internal/lib/webauthn"
+	"auth-service/internal/mailer"
 	"auth-service/internal/storage"
 	"context"
 	"errors"
 	"fmt"
-	"golang.org/x/crypto/bcrypt"
 	"log/slog"
 	"time"
 )
@@ -17,6 +20,115 @@ import (
 type Storage interface {
 	SaveUser(ctx context.Context, email string, passHash []byte) (uid int64, username string, err error)
 	GetUser(ctx context.Context, email string) (models.User, error)
+	GetUserByID(ctx context.Context, userID int64) (models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (models.User, error)
+	CreateSession(ctx context.Context, userID int64, client models.ClientInfo) (string, error)
+	GetSession(ctx context.Context, sessionID string) (models.Session, error)
+	TouchSession(ctx context.Context, sessionID string) error
+	SaveRefreshToken(ctx context.Context, token models.RefreshToken) error
+	UseRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeUserSessions(ctx context.Context, userID int64) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	SavePasswordReset(ctx context.Context, reset models.PasswordReset) error
+	ResetPassword(ctx context.Context, tokenHash string, passHash []byte) (userID int64, err error)
+	UpdatePassword(ctx context.Context, userID int64, passHash []byte) error
+	ReplacePasswordHash(ctx context.Context, userID int64, oldHash []byte, newHash []byte) error
+	RevokeOtherSessions(ctx context.Context, userID int64, keepSessionID string) error
+	GetUserByPasswordReset(ctx context.Context, tokenHash string) (models.User, error)
+	SaveTotp(ctx context.Context, userID int64, secret []byte) error
+	GetTotp(ctx context.Context, userID int64) (models.Totp, error)
+	ConfirmTotp(ctx context.Context, userID int64, step int64) error
+	UseTotpStep(ctx context.Context, userID int64, step int64) error
+	SaveMfaChallenge(ctx context.Context, challenge models.MfaChallenge) error
+	AttemptMfaChallenge(ctx context.Context, tokenHash string, maxAttempts int) (models.MfaChallenge, error)
+	ConsumeMfaChallenge(ctx context.Context, challengeID int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (remaining int, err error)
+	GetMfaChallenge(ctx context.Context, tokenHash string, maxAttempts int) (models.MfaChallenge, error)
+	ReserveAttempt(ctx context.Context, userID int64, kind string, maxFailures int, window time.Duration) error
+	ResetAttempts(ctx context.Context, userID int64, kind string) error
+	SaveWebauthnCredential(ctx context.Context, credential models.WebauthnCredential) (int64, error)
+	GetWebauthnCredentials(ctx context.Context, userID int64) ([]models.WebauthnCredential, error)
+	GetWebauthnCredential(ctx context.Context, credentialID []byte) (models.WebauthnCredential, error)
+	UseWebauthnCredential(ctx context.Context, id int64, signCount int64, backedUp bool) error
+	SaveWebauthnChallenge(ctx context.Context, challenge models.WebauthnChallenge) error
+	ConsumeWebauthnChallenge(ctx context.Context, challengeHash string, ceremony string) (models.WebauthnChallenge, error)
+	SaveLoginCode(ctx context.Context, code models.LoginCode, resendInterval time.Duration) error
+	AttemptLoginCode(ctx context.Context, userID int64, maxAttempts int) (models.LoginCode, error)
+	ConsumeLoginCode(ctx context.Context, codeID int64) error
+	GetOAuthClient(ctx context.Context, clientID string) (models.OAuthClient, error)
+	SaveOAuthCode(ctx context.Context, code models.OAuthCode) error
+	UseOAuthCode(ctx context.Context, codeHash string, client models.ClientInfo) (models.OAuthCode, error)
+	GetOAuthConsent(ctx context.Context, userID int64, clientID string) ([]string, error)
+	SaveOAuthConsent(ctx context.Context, userID int64, clientID string, scopes []string) error
+}
+
+type EmailVerifier interface {
+	SendVerification(ctx context.Context, user models.User) error
+}
+
+type Mailer interface {
+	SendTemplate(ctx context.Context, to string, template string, data any) error
+}
+
+// PasswordHasher verifies stored hashes, needsRehash reports hash made with outdated algorithm or parameters
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	Verify(password string, hash []byte) (match bool, needsRehash bool, err error)
+}
+
+// SecretCipher encrypts secrets stored in database, associated data binds ciphertext to its owner
+type SecretCipher interface {
+	Encrypt(plaintext []byte, associatedData []byte) ([]byte, error)
+	Decrypt(ciphertext []byte, associatedData []byte) ([]byte, error)
+}
+
+// PasswordPolicy checks new passwords, personal are email and username of the owner
+type PasswordPolicy interface {
+	Validate(password string, personal ...string) error
+}
+
+// PasswordResetOptions configure reset links, token is appended to URL as ?token=
+type PasswordResetOptions struct {
+	TokenTTL time.Duration
+	URL      string
+}
+
+// LoginCodeOptions configure passwordless login by email, link token and email are appended to URL.
+// Code accepts at most MaxAttempts tries within TTL, new code is not sent more often than ResendInterval,
+// user gets at most MaxFailures failed tries across codes within FailureWindow
+type LoginCodeOptions struct {
+	TTL            time.Duration
+	MaxAttempts    int
+	ResendInterval time.Duration
+	URL            string
+	MaxFailures    int
+	FailureWindow  time.Duration
+}
+
+// MfaOptions configure second factor, Issuer is shown in authenticator apps.
+// Challenge returned by Login accepts at most MaxAttempts codes within ChallengeTTL,
+// user gets at most MaxFailures failed codes across challenges within FailureWindow
+type MfaOptions struct {
+	Issuer        string
+	ChallengeTTL  time.Duration
+	MaxAttempts   int
+	MaxFailures   int
+	FailureWindow time.Duration
+}
+
+// OAuthOptions configure authorization server, authorization codes are valid for CodeTTL
+type OAuthOptions struct {
+	CodeTTL time.Duration
+}
+
+// LoginResult has either token pair or MfaToken, if the second factor is required to complete login
+type LoginResult struct {
+	AccessToken  string
+	RefreshToken string
+	MfaToken     string
 }
 
 type AuthService struct {
@@ -25,10 +137,47 @@ type AuthService struct {
 	accessTokenTTL  time.Duration
 	refreshTokenTTL time.Duration
 	kafkaProducer   *kafka.KafkaProducer
+	verifier        EmailVerifier
+	mailer          Mailer
+	passwordReset   PasswordResetOptions
+	loginCode       LoginCodeOptions
+	oauth           OAuthOptions
+	passwordPolicy  PasswordPolicy
+	passwordHasher  PasswordHasher
+	// secretCipher is nil when TOTP is disabled
+	secretCipher SecretCipher
+	mfa          MfaOptions
+	relyingParty *webauthn.RelyingParty
+	// requireVerifiedEmail blocks Login until email is verified
+	requireVerifiedEmail bool
 }
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrInvalidToken       = errors.New("invalid token")
+	ErrTokenReused        = errors.New("refresh token reused")
+	ErrEmailNotVerified   = errors.New("email is not verified")
+	ErrInvalidResetToken  = errors.New("invalid password reset token")
+	ErrSamePassword       = errors.New("new password must differ from the current one")
+	ErrInvalidLoginCode   = errors.New("invalid login code")
+	ErrMfaEnabled         = errors.New("second factor is already enabled")
+	ErrMfaNotEnrolled     = errors.New("second factor enrollment not started")
+	ErrInvalidMfaToken    = errors.New("invalid mfa token")
+	ErrInvalidMfaCode     = errors.New("invalid mfa code")
+	ErrTotpDisabled       = errors.New("totp is disabled")
+	ErrTooManyAttempts    = errors.New("too many failed attempts")
+
+	ErrInvalidWebauthn          = errors.New("invalid webauthn response")
+	ErrWebauthnNotRegistered    = errors.New("no webauthn credentials registered")
+	ErrWebauthnCredentialExists = errors.New("webauthn credential already registered")
+
+	ErrInvalidClient           = errors.New("invalid oauth client")
+	ErrInvalidRedirectURI      = errors.New("redirect uri is not registered for client")
+	ErrInvalidOAuthRequest     = errors.New("invalid authorization request")
+	ErrUnsupportedResponseType = errors.New("unsupported response type")
+	ErrInvalidScope            = errors.New("invalid scope")
+	ErrInvalidGrant            = errors.New("invalid grant")
+	ErrAccessDenied            = errors.New("access denied by user")
 )
 
 func NewAuthService(
@@ -37,13 +186,35 @@ func NewAuthService(
 	accessTokenTTL time.Duration,
 	refreshTokenTTL time.Duration,
 	kafkaProducer *kafka.KafkaProducer,
+	verifier EmailVerifier,
+	mailer Mailer,
+	passwordReset PasswordResetOptions,
+	loginCode LoginCodeOptions,
+	oauth OAuthOptions,
+	passwordPolicy PasswordPolicy,
+	passwordHasher PasswordHasher,
+	secretCipher SecretCipher,
+	mfa MfaOptions,
+	relyingParty *webauthn.RelyingParty,
+	requireVerifiedEmail bool,
 ) *AuthService {
 	return &AuthService{
-		log:             log,
-		storage:         storage,
-		accessTokenTTL:  accessTokenTTL,
-		refreshTokenTTL: refreshTokenTTL,
-		kafkaProducer:   kafkaProducer,
+		log:                  log,
+		storage:              storage,
+		accessTokenTTL:       accessTokenTTL,
+		refreshTokenTTL:      refreshTokenTTL,
+		kafkaProducer:        kafkaProducer,
+		verifier:             verifier,
+		mailer:               mailer,
+		passwordReset:        passwordReset,
+		loginCode:            loginCode,
+		oauth:                oauth,
+		passwordPolicy:       passwordPolicy,
+		passwordHasher:       passwordHasher,
+		secretCipher:         secretCipher,
+		mfa:                  mfa,
+		relyingParty:         relyingParty,
+		requireVerifiedEmail: requireVerifiedEmail,
 	}
 }
 
@@ -55,7 +226,12 @@ func (a *AuthService) Register(ctx context.Context, email string, password strin
 		slog.String("email", email),
 	)
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	// username is generated from email, so checking email covers it
+	if err := a.validatePassword(log, password, email); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := a.passwordHasher.Hash(password)
 	if err != nil {
 		log.Error("failed to generate password hash", sl.Err(err))
 		return 0, fmt.Errorf("%s: %w", op, err)
@@ -76,14 +252,36 @@ func (a *AuthService) Register(ctx context.Context, email string, password strin
 		return 0, err
 	}
 
+	// sent in background, slow mail server must not fail registration and user can request email again.
+	// Errors are logged by SendVerification
+	user := models.User{ID: id, Username: username, Email: email}
+	go func() { _ = a.verifier.SendVerification(context.WithoutCancel(ctx), user) }()
+
 	return id, nil
 }
 
+// validatePassword checks new password against policy, personal are email and username of its owner
+func (a *AuthService) validatePassword(log *slog.Logger, newPassword string, personal ...string) error {
+	err := a.passwordPolicy.Validate(newPassword, personal...)
+	if err != nil {
+		var policyErr *password.PolicyError
+		if errors.As(err, &policyErr) {
+			log.Debug("password rejected by policy", sl.Err(err))
+		} else {
+			log.Error("failed to check password", sl.Err(err))
+		}
+	}
+	return err
+}
+
+// Login checks password and starts session. Users with second factor get MfaToken instead of tokens,
+// CompleteMfaLogin exchanges it together with a code for the token pair
 func (a *AuthService) Login(
 	ctx context.Context,
 	email string,
 	password string,
-) (string, string, error) {
+	client models.ClientInfo,
+) (LoginResult, error) {
 	const op = "auth.Login"
 
 	log := a.log.With(
@@ -95,63 +293,576 @@ func (a *AuthService) Login(
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			log.Error("user not found", sl.Err(err))
-			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			return LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 		}
 		log.Error("failed to get user", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.Password, []byte(password)); err != nil {
-		log.Error("invalid credentials", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	match, needsRehash, err := a.passwordHasher.Verify(password, user.Password)
+	if err != nil {
+		log.Error("failed to verify password", sl.Err(err))
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !match {
+		log.Error("invalid credentials")
+		return LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+	if needsRehash {
+		a.rehashPassword(ctx, log, user, password)
 	}
 
-	accessToken, err := jwt.NewToken(user, a.accessTokenTTL, "access")
-	refreshToken, err := jwt.NewToken(user, a.refreshTokenTTL, "refresh")
+	if a.requireVerifiedEmail && !user.EmailVerified {
+		log.Warn("email is not verified")
+		return LoginResult{}, fmt.Errorf("%s: %w", op, ErrEmailNotVerified)
+	}
+
+	result, err := a.completeLogin(ctx, log, user, client)
 	if err != nil {
-		log.Error("failed to generate token", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		return LoginResult{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return accessToken, refreshToken, nil
+	return result, nil
+}
+
+// completeLogin follows checked first factor: asks for the second one if user has it, starts session otherwise
+func (a *AuthService) completeLogin(
+	ctx context.Context,
+	log *slog.Logger,
+	user models.User,
+	client models.ClientInfo,
+) (LoginResult, error) {
+	mfaToken, err := a.startMfaChallenge(ctx, user)
+	if err != nil {
+		log.Error("failed to start mfa challenge", sl.Err(err))
+		return LoginResult{}, err
+	}
+	if mfaToken != "" {
+		log.Info("second factor required")
+		return LoginResult{MfaToken: mfaToken}, nil
+	}
+
+	accessToken, refreshToken, err := a.startSession(ctx, user, client)
+	if err != nil {
+		log.Error("failed to start session", sl.Err(err))
+		return LoginResult{}, err
+	}
+
+	return LoginResult{AccessToken: accessToken, RefreshToken: refreshToken}, nil
 }
 
+// startSession creates session of user logged in directly and issues its first token pair,
+// such tokens are not bound to any client and get default audience
+func (a *AuthService) startSession(
+	ctx context.Context,
+	user models.User,
+	client models.ClientInfo,
+) (string, string, error) {
+	sessionID, err := a.storage.CreateSession(ctx, user.ID, client)
+	if err != nil {
+		return "", "", err
+	}
+
+	return a.issueTokens(ctx, user, sessionID, "", "")
+}
+
+// rehashPassword upgrades stored hash to current algorithm and parameters while plain password is known.
+// Old hash still works, so failure only postpones the upgrade to the next login
+func (a *AuthService) rehashPassword(ctx context.Context, log *slog.Logger, user models.User, password string) {
+	passHash, err := a.passwordHasher.Hash(password)
+	if err != nil {
+		log.Error("failed to rehash password", sl.Err(err))
+		return
+	}
+
+	if err := a.storage.ReplacePasswordHash(ctx, user.ID, user.Password, passHash); err != nil {
+		log.Error("failed to update password hash", sl.Err(err))
+		return
+	}
+
+	log.Info("password rehashed")
+}
+
+// Refresh exchanges refresh token for a new pair. Every refresh token is single-use:
+// presenting already used one means it was stolen, so the whole session is revoked.
+// Tokens of OAuth clients are refreshed by RefreshOAuthToken only, it authenticates the client
 func (a *AuthService) Refresh(
 	ctx context.Context,
 	refreshToken string,
 ) (string, string, error) {
-	const op = "auth.Login"
+	const op = "auth.Refresh"
 
 	log := a.log.With(slog.String("op", op))
 
-	refreshPayload, err := jwt.ParseToken(refreshToken)
+	refreshPayload, err := jwt.ParseToken(refreshToken, jwt.TokenTypeRefresh)
 	if err != nil {
 		log.Error("failed to parse token", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+	if refreshPayload.ClientID != "" || refreshPayload.Scope != "" {
+		log.Warn("refresh token of oauth client", slog.String("client_id", refreshPayload.ClientID))
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
 	}
 
-	if time.Now().Unix() > refreshPayload.Exp {
-		log.Error("token expired", sl.Err(err))
+	newAccessToken, newRefreshToken, err := a.rotateRefreshToken(ctx, log, refreshToken, refreshPayload)
+	if err != nil {
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	user, err := a.storage.GetUser(ctx, refreshPayload.Email)
+	return newAccessToken, newRefreshToken, nil
+}
+
+// rotateRefreshToken uses parsed refresh token and issues a new pair with the same client and scope
+func (a *AuthService) rotateRefreshToken(
+	ctx context.Context,
+	log *slog.Logger,
+	refreshToken string,
+	refreshPayload *jwt.Claims,
+) (string, string, error) {
+	stored, err := a.storage.UseRefreshToken(ctx, secret.Hash(refreshToken))
 	if err != nil {
-		log.Error("user not found", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, storage.ErrRefreshTokenUsed) {
+			log.Warn("refresh token reuse detected, revoking session",
+				slog.String("session_id", stored.SessionID),
+				slog.Int64("uid", stored.UserID),
+			)
+			if err := a.storage.RevokeSession(ctx, stored.SessionID); err != nil {
+				log.Error("failed to revoke session", sl.Err(err))
+				return "", "", err
+			}
+			return "", "", ErrTokenReused
+		}
+		if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+			log.Error("refresh token not found", sl.Err(err))
+			return "", "", ErrInvalidToken
+		}
+		log.Error("failed to use refresh token", sl.Err(err))
+		return "", "", err
 	}
 
-	newAccessToken, err := jwt.NewToken(user, a.accessTokenTTL, "access")
+	if stored.SessionRevoked {
+		log.Error("session revoked", slog.String("session_id", stored.SessionID))
+		return "", "", ErrInvalidToken
+	}
+
+	user, err := a.storage.GetUserByID(ctx, stored.UserID)
 	if err != nil {
-		log.Error("can not gen new access token", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		log.Error("user not found", sl.Err(err))
+		return "", "", err
 	}
 
-	newRefreshToken, err := jwt.NewToken(user, a.refreshTokenTTL, "refresh")
+	if err := a.storage.TouchSession(ctx, stored.SessionID); err != nil {
+		log.Error("failed to touch session", sl.Err(err))
+		return "", "", err
+	}
+
+	newAccessToken, newRefreshToken, err := a.issueTokens(ctx, user, stored.SessionID, refreshPayload.ClientID, refreshPayload.Scope)
 	if err != nil {
-		log.Error("can not gen new refresh token", sl.Err(err))
-		return "", "", fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to issue tokens", sl.Err(err))
+		return "", "", err
 	}
 
 	return newAccessToken, newRefreshToken, nil
 }
+
+// issueTokens signs access and refresh tokens for session and persists refresh token hash,
+// scope is set for tokens of OAuth clients only
+func (a *AuthService) issueTokens(
+	ctx context.Context,
+	user models.User,
+	sessionID string,
+	clientID string,
+	scope string,
+) (string, string, error) {
+	accessToken, accessPayload, err := jwt.NewToken(user, a.accessTokenTTL, jwt.TokenTypeAccess, sessionID, clientID, scope)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, refreshPayload, err := jwt.NewToken(user, a.refreshTokenTTL, jwt.TokenTypeRefresh, sessionID, clientID, scope)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = a.storage.SaveRefreshToken(ctx, models.RefreshToken{
+		SessionID:            sessionID,
+		TokenHash:            secret.Hash(refreshToken),
+		ExpiresAt:            refreshPayload.ExpiresAt.Time,
+		AccessTokenID:        accessPayload.ID,
+		AccessTokenExpiresAt: accessPayload.ExpiresAt.Time,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// ParseAccessToken verifies access token and makes sure it was not revoked by logout
+func (a *AuthService) ParseAccessToken(
+	ctx context.Context,
+	accessToken string,
+) (*jwt.Claims, error) {
+	const op = "auth.ParseAccessToken"
+
+	log := a.log.With(slog.String("op", op))
+
+	payload, err := jwt.ParseToken(accessToken, jwt.TokenTypeAccess)
+	if err != nil {
+		log.Debug("failed to parse token", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	revoked, err := a.storage.IsTokenRevoked(ctx, payload.ID)
+	if err != nil {
+		log.Error("failed to check token revocation", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if revoked {
+		log.Debug("token revoked", slog.String("jti", payload.ID))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	return payload, nil
+}
+
+// Logout revokes session the refresh token belongs to
+func (a *AuthService) Logout(
+	ctx context.Context,
+	refreshToken string,
+) error {
+	const op = "auth.Logout"
+
+	log := a.log.With(slog.String("op", op))
+
+	payload, err := jwt.ParseToken(refreshToken, jwt.TokenTypeRefresh)
+	if err != nil {
+		log.Error("failed to parse token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if err := a.storage.RevokeSession(ctx, payload.SessionID); err != nil {
+		log.Error("failed to revoke session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// LogoutAll revokes every session of the access token owner
+func (a *AuthService) LogoutAll(
+	ctx context.Context,
+	accessToken string,
+) error {
+	const op = "auth.LogoutAll"
+
+	log := a.log.With(slog.String("op", op))
+
+	payload, err := a.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.RevokeUserSessions(ctx, payload.Uid); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *AuthService) RevokeUserSessions(
+	ctx context.Context,
+	username string,
+) error {
+	const op = "auth.RevokeUserSessions"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", username),
+	)
+
+	user, err := a.storage.GetUserByUsername(ctx, username)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.RevokeUserSessions(ctx, user.ID); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Introspect describes token as RFC 7662 does. Token of other than hinted type is looked up too.
+// Error is returned only on internal failures, any unusable token is just inactive
+func (a *AuthService) Introspect(
+	ctx context.Context,
+	token string,
+	tokenTypeHint string,
+) (models.TokenIntrospection, error) {
+	const op = "auth.Introspect"
+
+	log := a.log.With(slog.String("op", op))
+
+	inactive := models.TokenIntrospection{Active: false}
+
+	introspectors := []func(context.Context, string) (*jwt.Claims, error){
+		a.ParseAccessToken,
+		a.introspectRefreshToken,
+	}
+	if tokenTypeHint == models.TokenTypeHintRefresh {
+		introspectors[0], introspectors[1] = introspectors[1], introspectors[0]
+	}
+
+	for _, introspect := range introspectors {
+		payload, err := introspect(ctx, token)
+		if err != nil {
+			if errors.Is(err, ErrInvalidToken) {
+				continue
+			}
+			log.Error("failed to introspect token", sl.Err(err))
+			return inactive, fmt.Errorf("%s: %w", op, err)
+		}
+
+		tokenType := models.TokenTypeHintAccess
+		if payload.Type == jwt.TokenTypeRefresh {
+			tokenType = models.TokenTypeHintRefresh
+		}
+
+		return models.TokenIntrospection{
+			Active:    true,
+			Subject:   payload.Subject,
+			Email:     payload.Email,
+			ExpiresAt: payload.ExpiresAt.Unix(),
+			IssuedAt:  payload.IssuedAt.Unix(),
+			Scope:     payload.Scope,
+			ClientID:  payload.ClientID,
+			TokenType: tokenType,
+			Role:      payload.Role,
+			Roles:     payload.Roles,
+		}, nil
+	}
+
+	return inactive, nil
+}
+
+// introspectRefreshToken treats refresh token as active until it is exchanged or its session is revoked
+func (a *AuthService) introspectRefreshToken(ctx context.Context, token string) (*jwt.Claims, error) {
+	payload, err := jwt.ParseToken(token, jwt.TokenTypeRefresh)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	stored, err := a.storage.GetRefreshToken(ctx, secret.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if stored.Used || stored.SessionRevoked {
+		return nil, ErrInvalidToken
+	}
+
+	return payload, nil
+}
+
+// passwordResetEmail is data of password_reset template
+type passwordResetEmail struct {
+	Username  string
+	Link      string
+	ExpiresAt time.Time
+}
+
+// RequestPasswordReset mails single-use reset link to the user. Result is the same for unknown emails,
+// and the mail is sent in background, so neither response nor its timing reveals registered emails
+func (a *AuthService) RequestPasswordReset(
+	ctx context.Context,
+	email string,
+) error {
+	const op = "auth.RequestPasswordReset"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	user, err := a.storage.GetUser(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Debug("user not found")
+			return nil
+		}
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	go a.sendPasswordReset(context.WithoutCancel(ctx), log, user)
+
+	return nil
+}
+
+func (a *AuthService) sendPasswordReset(ctx context.Context, log *slog.Logger, user models.User) {
+	token := secret.Generate(32)
+	expiresAt := time.Now().Add(a.passwordReset.TokenTTL)
+
+	err := a.storage.SavePasswordReset(ctx, models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: secret.Hash(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Error("failed to save password reset", sl.Err(err))
+		return
+	}
+
+	link, err := mailer.TokenLink(a.passwordReset.URL, token)
+	if err != nil {
+		log.Error("failed to build password reset link", sl.Err(err))
+		return
+	}
+
+	err = a.mailer.SendTemplate(ctx, user.Email, "password_reset", passwordResetEmail{
+		Username:  user.Username,
+		Link:      link,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Error("failed to send password reset email", sl.Err(err))
+	}
+}
+
+// ResetPassword sets new password by reset token and revokes all sessions of the user
+func (a *AuthService) ResetPassword(
+	ctx context.Context,
+	token string,
+	newPassword string,
+) error {
+	const op = "auth.ResetPassword"
+
+	log := a.log.With(slog.String("op", op))
+
+	tokenHash := secret.Hash(token)
+
+	// token is only looked up here, so rejected password can be fixed and sent with the same link
+	user, err := a.storage.GetUserByPasswordReset(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrPasswordResetNotFound) {
+			log.Debug("password reset not found or already used")
+			return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.validatePassword(log, newPassword, user.Email, user.Username); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := a.passwordHasher.Hash(newPassword)
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := a.storage.ResetPassword(ctx, tokenHash, passHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrPasswordResetNotFound) {
+			log.Debug("password reset not found or already used")
+			return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
+		log.Error("failed to reset password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.RevokeUserSessions(ctx, userID); err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset", slog.Int64("uid", userID))
+
+	return nil
+}
+
+// ChangePassword replaces password of the access token owner after checking the current one.
+// Other sessions are revoked, the current one too unless keepCurrentSession is set
+func (a *AuthService) ChangePassword(
+	ctx context.Context,
+	accessToken string,
+	oldPassword string,
+	newPassword string,
+	keepCurrentSession bool,
+) error {
+	const op = "auth.ChangePassword"
+
+	log := a.log.With(slog.String("op", op))
+
+	payload, err := a.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", payload.Uid))
+
+	user, err := a.storage.GetUserByID(ctx, payload.Uid)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	match, _, err := a.passwordHasher.Verify(oldPassword, user.Password)
+	if err != nil {
+		log.Error("failed to verify password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !match {
+		log.Warn("invalid current password")
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+	if oldPassword == newPassword {
+		return fmt.Errorf("%s: %w", op, ErrSamePassword)
+	}
+	if err := a.validatePassword(log, newPassword, user.Email, user.Username); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passHash, err := a.passwordHasher.Hash(newPassword)
+	if err != nil {
+		log.Error("failed to generate password hash", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.UpdatePassword(ctx, user.ID, passHash); err != nil {
+		log.Error("failed to update password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// tokens issued before sessions existed have no sid, so there is nothing to keep
+	if keepCurrentSession && payload.SessionID != "" {
+		err = a.storage.RevokeOtherSessions(ctx, user.ID, payload.SessionID)
+	} else {
+		err = a.storage.RevokeUserSessions(ctx, user.ID)
+	}
+	if err != nil {
+		log.Error("failed to revoke sessions", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// password is already changed, so failed notification does not fail the call
+	nativePasswordChanged := map[string]interface{}{
+		"username":   user.Username,
+		"email":      user.Email,
+		"changed_at": time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := a.kafkaProducer.ProducePasswordChanged(user.Email, nativePasswordChanged); err != nil {
+		log.Error("failed to produce password changed event", sl.Err(err))
+	}
+
+	log.Info("password changed")
+
+	return nil
+}
@@ -0,0 +1,369 @@
+package authservice
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/secret"
+	"auth-service/internal/lib/sl"
+	"auth-service/internal/lib/webauthn"
+	"auth-service/internal/storage"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// BeginWebauthnRegistration starts passkey registration for the access token owner,
+// returned creation options JSON is passed to navigator.credentials.create
+func (a *AuthService) BeginWebauthnRegistration(
+	ctx context.Context,
+	accessToken string,
+) (string, error) {
+	const op = "auth.BeginWebauthnRegistration"
+
+	log := a.log.With(slog.String("op", op))
+
+	payload, err := a.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", payload.Uid))
+
+	user, err := a.storage.GetUserByID(ctx, payload.Uid)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// authenticator already holding credential of user refuses to create another one
+	exclude, err := a.webauthnDescriptors(ctx, user.ID)
+	if err != nil {
+		log.Error("failed to get webauthn credentials", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := a.startWebauthnChallenge(ctx, &user.ID, models.WebauthnCeremonyRegistration)
+	if err != nil {
+		log.Error("failed to save webauthn challenge", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	options, err := a.relyingParty.CreationOptions(challenge, webauthn.User{
+		Handle:      webauthnUserHandle(user.ID),
+		Name:        user.Email,
+		DisplayName: user.Username,
+	}, exclude)
+	if err != nil {
+		log.Error("failed to build creation options", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return string(options), nil
+}
+
+// FinishWebauthnRegistration verifies response of navigator.credentials.create and saves the credential
+func (a *AuthService) FinishWebauthnRegistration(
+	ctx context.Context,
+	accessToken string,
+	response string,
+	name string,
+) (int64, error) {
+	const op = "auth.FinishWebauthnRegistration"
+
+	log := a.log.With(slog.String("op", op))
+
+	payload, err := a.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", payload.Uid))
+
+	registration, err := webauthn.ParseRegistration([]byte(response))
+	if err != nil {
+		log.Warn("invalid webauthn registration", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+	}
+
+	challenge, err := a.storage.ConsumeWebauthnChallenge(ctx, webauthnChallengeHash(registration.Challenge), models.WebauthnCeremonyRegistration)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebauthnChallengeNotFound) {
+			log.Warn("webauthn challenge not found, expired or used")
+			return 0, fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+		}
+		log.Error("failed to consume webauthn challenge", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if challenge.UserID == nil || *challenge.UserID != payload.Uid {
+		log.Warn("webauthn challenge of other user")
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+	}
+
+	credential, err := a.relyingParty.VerifyRegistration(registration, registration.Challenge, false)
+	if err != nil {
+		log.Warn("webauthn registration rejected", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+	}
+
+	id, err := a.storage.SaveWebauthnCredential(ctx, models.WebauthnCredential{
+		UserID:         payload.Uid,
+		CredentialID:   credential.ID,
+		PublicKey:      credential.PublicKey,
+		SignCount:      int64(credential.SignCount),
+		AAGUID:         credential.AAGUID,
+		Transports:     credential.Transports,
+		Name:           name,
+		BackupEligible: credential.BackupEligible,
+		BackedUp:       credential.BackedUp,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrWebauthnCredentialExists) {
+			return 0, fmt.Errorf("%s: %w", op, ErrWebauthnCredentialExists)
+		}
+		log.Error("failed to save webauthn credential", sl.Err(err))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("webauthn credential registered", slog.Int64("credential", id))
+
+	return id, nil
+}
+
+// BeginWebauthnLogin starts passkey login and returns request options JSON for navigator.credentials.get.
+// Without mfaToken passkey is the only factor: any discoverable credential is offered and user verification is required.
+// With mfaToken from Login passkey is the second factor, only credentials of the challenge owner are offered
+func (a *AuthService) BeginWebauthnLogin(
+	ctx context.Context,
+	mfaToken string,
+) (string, error) {
+	const op = "auth.BeginWebauthnLogin"
+
+	log := a.log.With(slog.String("op", op))
+
+	var userID *int64
+	var allow []webauthn.CredentialDescriptor
+	userVerification := webauthn.UserVerificationRequired
+
+	if mfaToken != "" {
+		mfaChallenge, err := a.storage.GetMfaChallenge(ctx, secret.Hash(mfaToken), a.mfa.MaxAttempts)
+		if err != nil {
+			if errors.Is(err, storage.ErrMfaChallengeNotFound) {
+				log.Debug("mfa challenge not found, expired or out of attempts")
+				return "", fmt.Errorf("%s: %w", op, ErrInvalidMfaToken)
+			}
+			log.Error("failed to get mfa challenge", sl.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		log = log.With(slog.Int64("uid", mfaChallenge.UserID))
+
+		allow, err = a.webauthnDescriptors(ctx, mfaChallenge.UserID)
+		if err != nil {
+			log.Error("failed to get webauthn credentials", sl.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		if len(allow) == 0 {
+			return "", fmt.Errorf("%s: %w", op, ErrWebauthnNotRegistered)
+		}
+
+		userID = &mfaChallenge.UserID
+		userVerification = webauthn.UserVerificationPreferred
+	}
+
+	challenge, err := a.startWebauthnChallenge(ctx, userID, models.WebauthnCeremonyLogin)
+	if err != nil {
+		log.Error("failed to save webauthn challenge", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	options, err := a.relyingParty.RequestOptions(challenge, allow, userVerification)
+	if err != nil {
+		log.Error("failed to build request options", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return string(options), nil
+}
+
+// FinishWebauthnLogin verifies response of navigator.credentials.get and starts session.
+// mfaToken must be the same BeginWebauthnLogin was called with
+func (a *AuthService) FinishWebauthnLogin(
+	ctx context.Context,
+	response string,
+	mfaToken string,
+	client models.ClientInfo,
+) (string, string, error) {
+	const op = "auth.FinishWebauthnLogin"
+
+	log := a.log.With(slog.String("op", op))
+
+	assertion, err := webauthn.ParseAssertion([]byte(response))
+	if err != nil {
+		log.Warn("invalid webauthn assertion", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+	}
+
+	var mfaChallenge models.MfaChallenge
+	if mfaToken != "" {
+		mfaChallenge, err = a.storage.AttemptMfaChallenge(ctx, secret.Hash(mfaToken), a.mfa.MaxAttempts)
+		if err != nil {
+			if errors.Is(err, storage.ErrMfaChallengeNotFound) {
+				log.Debug("mfa challenge not found, expired or out of attempts")
+				return "", "", fmt.Errorf("%s: %w", op, ErrInvalidMfaToken)
+			}
+			log.Error("failed to attempt mfa challenge", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+		log = log.With(slog.Int64("uid", mfaChallenge.UserID))
+
+		err = a.reserveAttempt(ctx, log, mfaChallenge.UserID, attemptKindMfa, a.mfa.MaxFailures, a.mfa.FailureWindow)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	challenge, err := a.storage.ConsumeWebauthnChallenge(ctx, webauthnChallengeHash(assertion.Challenge), models.WebauthnCeremonyLogin)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebauthnChallengeNotFound) {
+			log.Warn("webauthn challenge not found, expired or used")
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+		}
+		log.Error("failed to consume webauthn challenge", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// challenge of passwordless login must not complete mfa login and vice versa
+	secondFactor := mfaToken != ""
+	if secondFactor != (challenge.UserID != nil) || (secondFactor && *challenge.UserID != mfaChallenge.UserID) {
+		log.Warn("webauthn challenge of other login")
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+	}
+
+	credential, err := a.storage.GetWebauthnCredential(ctx, assertion.CredentialID)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebauthnCredentialNotFound) {
+			log.Warn("unknown webauthn credential")
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+		}
+		log.Error("failed to get webauthn credential", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("credential", credential.ID))
+
+	if secondFactor && credential.UserID != mfaChallenge.UserID {
+		log.Warn("webauthn credential of other user")
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+	}
+	// discoverable credential always returns user handle, it must be the one it was registered with
+	if (!secondFactor || assertion.UserHandle != nil) && !bytes.Equal(assertion.UserHandle, webauthnUserHandle(credential.UserID)) {
+		log.Warn("webauthn user handle mismatch")
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+	}
+
+	signCount, err := a.relyingParty.VerifyAssertion(assertion, assertion.Challenge, webauthn.Credential{
+		ID:        credential.CredentialID,
+		PublicKey: credential.PublicKey,
+		SignCount: uint32(credential.SignCount),
+	}, !secondFactor)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCount) {
+			log.Warn("webauthn signature counter did not increase, credential may be cloned",
+				slog.Int64("stored", credential.SignCount),
+				slog.Int64("received", int64(signCount)),
+			)
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+		}
+		if errors.Is(err, webauthn.ErrInvalidResponse) {
+			log.Warn("webauthn assertion rejected", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+		}
+		log.Error("failed to verify webauthn assertion", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.UseWebauthnCredential(ctx, credential.ID, int64(signCount), assertion.BackedUp()); err != nil {
+		if errors.Is(err, storage.ErrWebauthnSignCount) {
+			log.Warn("webauthn signature counter did not increase, credential may be cloned",
+				slog.Int64("stored", credential.SignCount),
+				slog.Int64("received", int64(signCount)),
+			)
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidWebauthn)
+		}
+		log.Error("failed to update webauthn credential", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if secondFactor {
+		return a.finishMfaLogin(ctx, log, op, mfaChallenge, client)
+	}
+
+	log = log.With(slog.Int64("uid", credential.UserID))
+
+	user, err := a.storage.GetUserByID(ctx, credential.UserID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.requireVerifiedEmail && !user.EmailVerified {
+		log.Warn("email is not verified")
+		return "", "", fmt.Errorf("%s: %w", op, ErrEmailNotVerified)
+	}
+
+	// verified passkey proves possession and user, so no other factor is asked
+	accessToken, refreshToken, err := a.startSession(ctx, user, client)
+	if err != nil {
+		log.Error("failed to start session", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("webauthn login completed")
+
+	return accessToken, refreshToken, nil
+}
+
+// webauthnDescriptors lists credentials of user for allow or exclude list of options
+func (a *AuthService) webauthnDescriptors(ctx context.Context, userID int64) ([]webauthn.CredentialDescriptor, error) {
+	credentials, err := a.storage.GetWebauthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptors := make([]webauthn.CredentialDescriptor, len(credentials))
+	for i, credential := range credentials {
+		descriptors[i] = webauthn.NewCredentialDescriptor(credential.CredentialID, credential.Transports)
+	}
+
+	return descriptors, nil
+}
+
+// startWebauthnChallenge stores hash of new challenge, it expires together with ceremony timeout
+func (a *AuthService) startWebauthnChallenge(ctx context.Context, userID *int64, ceremony string) ([]byte, error) {
+	challenge := webauthn.NewChallenge()
+
+	err := a.storage.SaveWebauthnChallenge(ctx, models.WebauthnChallenge{
+		UserID:        userID,
+		ChallengeHash: webauthnChallengeHash(challenge),
+		Ceremony:      ceremony,
+		ExpiresAt:     time.Now().Add(a.relyingParty.Timeout),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func webauthnChallengeHash(challenge []byte) string {
+	return secret.Hash(base64.RawURLEncoding.EncodeToString(challenge))
+}
+
+// webauthnUserHandle is user ID as 8 bytes, it identifies the account without personal data
+func webauthnUserHandle(userID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(userID))
+}
@@ -0,0 +1,168 @@
+package keyservice
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/jwt"
+	"auth-service/internal/lib/sl"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type Storage interface {
+	GetSigningKeys(ctx context.Context) ([]models.SigningKey, error)
+	SaveSigningKey(ctx context.Context, key models.SigningKey) error
+	PromoteSigningKey(ctx context.Context, kid string, verifyUntil time.Time) error
+	RetireSigningKey(ctx context.Context, kid string, verifyUntil time.Time) error
+}
+
+type KeyService struct {
+	log         *slog.Logger
+	storage     Storage
+	gracePeriod time.Duration
+}
+
+var (
+	ErrActiveKey = errors.New("active key can not be retired, promote another key first")
+)
+
+func NewKeyService(
+	log *slog.Logger,
+	storage Storage,
+	gracePeriod time.Duration,
+) *KeyService {
+	return &KeyService{
+		log:         log,
+		storage:     storage,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// Generate creates new pending key. It is published in JWKS right away but signs nothing until promoted
+func (s *KeyService) Generate(ctx context.Context, algorithm string) (models.SigningKey, error) {
+	const op = "keys.Generate"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("algorithm", algorithm),
+	)
+
+	key, err := jwt.GenerateSigningKey(algorithm)
+	if err != nil {
+		log.Error("failed to generate key", sl.Err(err))
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.storage.SaveSigningKey(ctx, key); err != nil {
+		log.Error("failed to save key", sl.Err(err))
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// Promote starts signing with pending key. Previous active key keeps verifying tokens for the grace period
+func (s *KeyService) Promote(ctx context.Context, kid string) error {
+	const op = "keys.Promote"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("kid", kid),
+	)
+
+	if err := s.storage.PromoteSigningKey(ctx, kid, time.Now().Add(s.gracePeriod)); err != nil {
+		log.Error("failed to promote key", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Retire stops accepting tokens signed with key after the grace period
+func (s *KeyService) Retire(ctx context.Context, kid string) error {
+	const op = "keys.Retire"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("kid", kid),
+	)
+
+	keys, err := s.storage.GetSigningKeys(ctx)
+	if err != nil {
+		log.Error("failed to get keys", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, key := range keys {
+		if key.ID == kid && key.Status == models.KeyStatusActive {
+			return fmt.Errorf("%s: %w", op, ErrActiveKey)
+		}
+	}
+
+	if err := s.storage.RetireSigningKey(ctx, kid, time.Now().Add(s.gracePeriod)); err != nil {
+		log.Error("failed to retire key", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *KeyService) List(ctx context.Context) ([]models.SigningKey, error) {
+	const op = "keys.List"
+
+	keys, err := s.storage.GetSigningKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// LoadKeyRing reads keys from storage and installs them for jwt.NewToken and jwt.ParseToken
+func (s *KeyService) LoadKeyRing(ctx context.Context) error {
+	const op = "keys.LoadKeyRing"
+
+	stored, err := s.storage.GetSigningKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys := make([]*jwt.SigningKey, 0, len(stored))
+	for _, model := range stored {
+		key, err := jwt.NewSigningKey(model)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+
+	keyRing, err := jwt.NewKeyRing(keys)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	jwt.SetKeyRing(keyRing)
+
+	return nil
+}
+
+// RunReload periodically reloads key ring so rotation done by cmd/keys is picked up without restart
+func (s *KeyService) RunReload(ctx context.Context, interval time.Duration) {
+	const op = "keys.RunReload"
+
+	log := s.log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.LoadKeyRing(ctx); err != nil {
+				log.Error("failed to reload key ring, keeping previous one", sl.Err(err))
+			}
+		}
+	}
+}
@@ -16,20 +16,33 @@ type Storage interface {
 	GetUserByID(ctx context.Context, userID int64) (models.User, error)
 	GetUsers(ctx context.Context, roleID *int64, nameStartsWith *string) ([]models.User, error)
 	DeleteUserByUsername(ctx context.Context, username string) error
+	GetUserSessions(ctx context.Context, userID int64) ([]models.Session, error)
+	RevokeUserSession(ctx context.Context, userID int64, sessionID string) error
+}
+
+// TokenParser verifies access token and makes sure it was not revoked, it is shared with auth interceptor
+type TokenParser interface {
+	ParseAccessToken(
+		ctx context.Context,
+		accessToken string,
+	) (*jwt.Claims, error)
 }
 
 type UserService struct {
-	log     *slog.Logger
-	storage Storage
+	log         *slog.Logger
+	storage     Storage
+	tokenParser TokenParser
 }
 
 func NewUserService(
 	log *slog.Logger,
 	storage Storage,
+	tokenParser TokenParser,
 ) *UserService {
 	return &UserService{
-		log:     log,
-		storage: storage,
+		log:         log,
+		storage:     storage,
+		tokenParser: tokenParser,
 	}
 }
 
@@ -63,9 +76,9 @@ func (s *UserService) GetUserByToken(
 		slog.String("op", op),
 	)
 
-	payload, err := jwt.ParseToken(accessToken)
+	payload, err := s.tokenParser.ParseAccessToken(ctx, accessToken)
 	if err != nil {
-		log.Error("failed to parse token", sl.Err(err))
+		log.Error("failed to resolve token", sl.Err(err))
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -95,9 +108,75 @@ func (s *UserService) DeleteUser(
 			log.Error("user not found", sl.Err(err))
 			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
 		}
+		if errors.Is(err, storage.ErrLastAdmin) {
+			log.Warn("refused to delete the last admin")
+			return fmt.Errorf("%s: %w", op, storage.ErrLastAdmin)
+		}
 		log.Error("failed to delete user", sl.Err(err))
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
 }
+
+// ListSessions returns active sessions of the access token owner
+func (s *UserService) ListSessions(
+	ctx context.Context,
+	accessToken string,
+) ([]models.Session, error) {
+	const op = "user.ListSessions"
+
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	payload, err := s.tokenParser.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		log.Error("failed to resolve token", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := s.storage.GetUserSessions(ctx, payload.Uid)
+	if err != nil {
+		log.Error("failed to get sessions", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == payload.SessionID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes one of the access token owner sessions
+func (s *UserService) RevokeSession(
+	ctx context.Context,
+	accessToken string,
+	sessionID string,
+) error {
+	const op = "user.RevokeSession"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("session_id", sessionID),
+	)
+
+	payload, err := s.tokenParser.ParseAccessToken(ctx, accessToken)
+	if err != nil {
+		log.Error("failed to resolve token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.storage.RevokeUserSession(ctx, payload.Uid, sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Error("session not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+		log.Error("failed to revoke session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package verificationservice
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/lib/jwt"
+	"auth-service/internal/lib/secret"
+	"auth-service/internal/lib/sl"
+	"auth-service/internal/mailer"
+	"auth-service/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type Storage interface {
+	GetUser(ctx context.Context, email string) (models.User, error)
+	SaveEmailVerification(ctx context.Context, verification models.EmailVerification, resendInterval time.Duration) error
+	VerifyEmail(ctx context.Context, tokenHash string) (userID int64, err error)
+}
+
+type Mailer interface {
+	SendTemplate(ctx context.Context, to string, template string, data any) error
+}
+
+// verificationEmail is data of verify_email template
+type verificationEmail struct {
+	Username  string
+	Link      string
+	ExpiresAt time.Time
+}
+
+var (
+	ErrInvalidToken = errors.New("invalid verification token")
+)
+
+type VerificationService struct {
+	log      *slog.Logger
+	storage  Storage
+	mailer   Mailer
+	tokenTTL time.Duration
+	// resendInterval is minimal interval between verification emails sent to one user
+	resendInterval time.Duration
+	url            string
+}
+
+// NewVerificationService creates service sending links of form verificationURL?token=<token>
+func NewVerificationService(
+	log *slog.Logger,
+	storage Storage,
+	mailer Mailer,
+	tokenTTL time.Duration,
+	resendInterval time.Duration,
+	verificationURL string,
+) *VerificationService {
+	return &VerificationService{
+		log:            log,
+		storage:        storage,
+		mailer:         mailer,
+		tokenTTL:       tokenTTL,
+		resendInterval: resendInterval,
+		url:            verificationURL,
+	}
+}
+
+// SendVerificationEmail sends new verification link to not yet verified user. Unknown and verified emails
+// are silently skipped and the mail is sent in background, so neither response nor its timing reveals registered emails
+func (s *VerificationService) SendVerificationEmail(
+	ctx context.Context,
+	email string,
+) error {
+	const op = "verification.SendVerificationEmail"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+	)
+
+	user, err := s.storage.GetUser(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Debug("user not found")
+			return nil
+		}
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if user.EmailVerified {
+		log.Debug("email already verified")
+		return nil
+	}
+
+	// errors are logged by SendVerification
+	go func() { _ = s.SendVerification(context.WithoutCancel(ctx), user) }()
+
+	return nil
+}
+
+// SendVerification issues signed single-use verification token for user email and mails link with it,
+// it fails with ErrVerificationTooSoon if the previous link was sent less than resendInterval ago
+func (s *VerificationService) SendVerification(
+	ctx context.Context,
+	user models.User,
+) error {
+	const op = "verification.SendVerification"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Int64("uid", user.ID),
+	)
+
+	token, payload, err := jwt.NewToken(user, s.tokenTTL, jwt.TokenTypeEmailVerification, "", "", "")
+	if err != nil {
+		log.Error("failed to issue verification token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.storage.SaveEmailVerification(ctx, models.EmailVerification{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: secret.Hash(token),
+		ExpiresAt: payload.ExpiresAt.Time,
+	}, s.resendInterval)
+	if err != nil {
+		if errors.Is(err, storage.ErrVerificationTooSoon) {
+			log.Debug("verification email was sent recently")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("failed to save verification", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	link, err := mailer.TokenLink(s.url, token)
+	if err != nil {
+		log.Error("failed to build verification link", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.mailer.SendTemplate(ctx, user.Email, "verify_email", verificationEmail{
+		Username:  user.Username,
+		Link:      link,
+		ExpiresAt: payload.ExpiresAt.Time,
+	})
+	if err != nil {
+		log.Error("failed to send verification email", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// VerifyEmail consumes verification token and marks email of its owner verified
+func (s *VerificationService) VerifyEmail(
+	ctx context.Context,
+	token string,
+) error {
+	const op = "verification.VerifyEmail"
+
+	log := s.log.With(slog.String("op", op))
+
+	if _, err := jwt.ParseToken(token, jwt.TokenTypeEmailVerification); err != nil {
+		log.Debug("failed to parse token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	userID, err := s.storage.VerifyEmail(ctx, secret.Hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrVerificationNotFound) {
+			log.Debug("verification not found or already used")
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		log.Error("failed to verify email", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email verified", slog.Int64("uid", userID))
+
+	return nil
+}
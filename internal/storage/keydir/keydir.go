@@ -0,0 +1,227 @@
+package keydir
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const manifestName = "keys.json"
+
+// Storage keeps signing keys in a directory: PEM file per key plus keys.json manifest with their state
+type Storage struct {
+	mu  sync.Mutex
+	dir string
+}
+
+type manifestEntry struct {
+	ID          string     `json:"kid"`
+	Algorithm   string     `json:"algorithm"`
+	File        string     `json:"file"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+	VerifyUntil *time.Time `json:"verify_until,omitempty"`
+}
+
+func NewStorage(dir string) (*Storage, error) {
+	const op = "storage.keydir.New"
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{dir: dir}, nil
+}
+
+func (s *Storage) GetSigningKeys(_ context.Context) ([]models.SigningKey, error) {
+	const op = "storage.keydir.GetSigningKeys"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readManifest()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	var keys []models.SigningKey
+	for _, entry := range entries {
+		if entry.VerifyUntil != nil && !now.Before(*entry.VerifyUntil) {
+			continue
+		}
+
+		privateKey, err := os.ReadFile(filepath.Join(s.dir, entry.File))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		keys = append(keys, models.SigningKey{
+			ID:          entry.ID,
+			Algorithm:   entry.Algorithm,
+			PrivateKey:  privateKey,
+			Status:      entry.Status,
+			CreatedAt:   entry.CreatedAt,
+			RetiredAt:   entry.RetiredAt,
+			VerifyUntil: entry.VerifyUntil,
+		})
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) SaveSigningKey(_ context.Context, key models.SigningKey) error {
+	const op = "storage.keydir.SaveSigningKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readManifest()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if findEntry(entries, key.ID) != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrKeyExists)
+	}
+
+	file := key.ID + ".pem"
+	if err := os.WriteFile(filepath.Join(s.dir, file), key.PrivateKey, 0o600); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	entries = append(entries, manifestEntry{
+		ID:        key.ID,
+		Algorithm: key.Algorithm,
+		File:      file,
+		Status:    key.Status,
+		CreatedAt: key.CreatedAt,
+	})
+	if err := s.writeManifest(entries); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PromoteSigningKey makes pending key active, retiring current active key with given verification deadline
+func (s *Storage) PromoteSigningKey(_ context.Context, kid string, verifyUntil time.Time) error {
+	const op = "storage.keydir.PromoteSigningKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readManifest()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	entry := findEntry(entries, kid)
+	if entry == nil || entry.Status != models.KeyStatusPending {
+		return fmt.Errorf("%s: %w", op, storage.ErrKeyNotFound)
+	}
+
+	now := time.Now()
+	for i := range entries {
+		if entries[i].Status == models.KeyStatusActive {
+			entries[i].Status = models.KeyStatusRetired
+			entries[i].RetiredAt = &now
+			entries[i].VerifyUntil = &verifyUntil
+		}
+	}
+	entry.Status = models.KeyStatusActive
+
+	if err := s.writeManifest(entries); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetireSigningKey(_ context.Context, kid string, verifyUntil time.Time) error {
+	const op = "storage.keydir.RetireSigningKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.readManifest()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	entry := findEntry(entries, kid)
+	if entry == nil || entry.Status == models.KeyStatusActive {
+		return fmt.Errorf("%s: %w", op, storage.ErrKeyNotFound)
+	}
+
+	if entry.RetiredAt == nil {
+		now := time.Now()
+		entry.RetiredAt = &now
+	}
+	entry.Status = models.KeyStatusRetired
+	entry.VerifyUntil = &verifyUntil
+
+	if err := s.writeManifest(entries); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) readManifest() ([]manifestEntry, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, manifestName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []manifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// writeManifest replaces manifest atomically so a running service never reads a partial file
+func (s *Storage) writeManifest(entries []manifestEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, manifestName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, manifestName))
+}
+
+func findEntry(entries []manifestEntry, kid string) *manifestEntry {
+	for i := range entries {
+		if entries[i].ID == kid {
+			return &entries[i]
+		}
+	}
+
+	return nil
+}
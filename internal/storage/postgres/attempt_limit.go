@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"auth-service/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReserveAttempt counts attempt of user as failed before it is checked, so concurrent attempts can not exceed
+// maxFailures. Counter restarts once window has passed since its first failure, it fails with ErrTooManyAttempts
+// while maxFailures are already counted within window
+func (s *Storage) ReserveAttempt(ctx context.Context, userID int64, kind string, maxFailures int, window time.Duration) error {
+	const op = "storage.postgres.ReserveAttempt"
+
+	query := `INSERT INTO attempt_limit AS l (user_id, kind, failures, window_start) VALUES ($1, $2, 1, now())
+			ON CONFLICT (user_id, kind) DO UPDATE SET
+				failures = CASE WHEN l.window_start < now() - make_interval(secs => $4) THEN 1 ELSE l.failures + 1 END,
+				window_start = CASE WHEN l.window_start < now() - make_interval(secs => $4) THEN now() ELSE l.window_start END
+			WHERE l.window_start < now() - make_interval(secs => $4) OR l.failures < $3
+			RETURNING failures`
+
+	var failures int
+	err := s.db.GetContext(ctx, &failures, query, userID, kind, maxFailures, window.Seconds())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrTooManyAttempts)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ResetAttempts forgets failures of user after successful attempt
+func (s *Storage) ResetAttempts(ctx context.Context, userID int64, kind string) error {
+	const op = "storage.postgres.ResetAttempts"
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM attempt_limit WHERE user_id = $1 AND kind = $2`, userID, kind)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
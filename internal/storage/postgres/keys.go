@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+func (s *Storage) GetSigningKeys(ctx context.Context) ([]models.SigningKey, error) {
+	const op = "storage.postgres.GetSigningKeys"
+
+	query := `SELECT kid, algorithm, private_key, status, created_at, retired_at, verify_until
+				FROM signing_key
+				WHERE verify_until IS NULL OR verify_until > now()
+				ORDER BY created_at`
+
+	var keys []models.SigningKey
+	err := s.db.SelectContext(ctx, &keys, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) SaveSigningKey(ctx context.Context, key models.SigningKey) error {
+	const op = "storage.postgres.SaveSigningKey"
+
+	query := `INSERT INTO signing_key (kid, algorithm, private_key, status, created_at) VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := s.db.ExecContext(ctx, query, key.ID, key.Algorithm, key.PrivateKey, key.Status, key.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrKeyExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PromoteSigningKey makes pending key active, retiring current active key with given verification deadline
+func (s *Storage) PromoteSigningKey(ctx context.Context, kid string, verifyUntil time.Time) error {
+	const op = "storage.postgres.PromoteSigningKey"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE signing_key
+				SET status = 'retired', retired_at = now(), verify_until = $1
+				WHERE status = 'active'`, verifyUntil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `UPDATE signing_key SET status = 'active' WHERE kid = $1 AND status = 'pending'`, kid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrKeyNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RetireSigningKey(ctx context.Context, kid string, verifyUntil time.Time) error {
+	const op = "storage.postgres.RetireSigningKey"
+
+	query := `UPDATE signing_key
+				SET status = 'retired', retired_at = COALESCE(retired_at, now()), verify_until = $2
+				WHERE kid = $1 AND status <> 'active'`
+
+	res, err := s.db.ExecContext(ctx, query, kid, verifyUntil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrKeyNotFound)
+	}
+
+	return nil
+}
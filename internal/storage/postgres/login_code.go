@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SaveLoginCode stores login code, earlier unused codes of the user stop working.
+// Nothing is saved if the previous code was created less than resendInterval ago
+func (s *Storage) SaveLoginCode(ctx context.Context, code models.LoginCode, resendInterval time.Duration) error {
+	const op = "storage.postgres.SaveLoginCode"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// lock user row, so concurrent requests can not both pass the interval check
+	_, err = tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, code.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var recent bool
+	err = tx.GetContext(ctx, &recent, `SELECT EXISTS (SELECT 1 FROM login_code
+				WHERE user_id = $1 AND created_at > now() - make_interval(secs => $2))`,
+		code.UserID, resendInterval.Seconds())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if recent {
+		return fmt.Errorf("%s: %w", op, storage.ErrLoginCodeTooSoon)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE login_code SET used_at = now()
+				WHERE user_id = $1 AND used_at IS NULL`, code.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO login_code (user_id, code_hash, token_hash, expires_at) VALUES ($1, $2, $3, $4)`,
+		code.UserID, code.CodeHash, code.TokenHash, code.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AttemptLoginCode counts attempt to use the current code of user,
+// code with maxAttempts attempts already made is not found anymore
+func (s *Storage) AttemptLoginCode(ctx context.Context, userID int64, maxAttempts int) (models.LoginCode, error) {
+	const op = "storage.postgres.AttemptLoginCode"
+
+	query := `UPDATE login_code SET attempts = attempts + 1
+			WHERE user_id = $1 AND used_at IS NULL AND expires_at > now() AND attempts < $2
+			RETURNING id, user_id, code_hash, token_hash, expires_at, attempts`
+
+	var code models.LoginCode
+	err := s.db.GetContext(ctx, &code, query, userID, maxAttempts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginCode{}, fmt.Errorf("%s: %w", op, storage.ErrLoginCodeNotFound)
+		}
+		return models.LoginCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return code, nil
+}
+
+// ConsumeLoginCode marks matched code used. Code came by email, so the email becomes verified too
+func (s *Storage) ConsumeLoginCode(ctx context.Context, codeID int64) error {
+	const op = "storage.postgres.ConsumeLoginCode"
+
+	query := `WITH c AS (
+				UPDATE login_code SET used_at = now()
+				WHERE id = $1 AND used_at IS NULL
+				RETURNING user_id
+			)
+			UPDATE users u SET email_verified = TRUE
+			FROM c
+			WHERE u.id = c.user_id`
+
+	res, err := s.db.ExecContext(ctx, query, codeID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrLoginCodeNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package postgres
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+// SaveTotp starts enrollment with new secret, pending one is replaced, confirmed one is kept
+func (s *Storage) SaveTotp(ctx context.Context, userID int64, secret []byte) error {
+	const op = "storage.postgres.SaveTotp"
+
+	query := `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE
+			SET secret = EXCLUDED.secret, last_used_step = 0, created_at = now()
+			WHERE user_totp.confirmed_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTotpConfirmed)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetTotp(ctx context.Context, userID int64) (models.Totp, error) {
+	const op = "storage.postgres.GetTotp"
+
+	query := `SELECT user_id, secret, confirmed_at, last_used_step FROM user_totp WHERE user_id = $1`
+
+	var totp models.Totp
+	err := s.db.GetContext(ctx, &totp, query, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Totp{}, fmt.Errorf("%s: %w", op, storage.ErrTotpNotFound)
+		}
+		return models.Totp{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return totp, nil
+}
+
+// ConfirmTotp finishes pending enrollment, step of the confirming code can not be used again
+func (s *Storage) ConfirmTotp(ctx context.Context, userID int64, step int64) error {
+	const op = "storage.postgres.ConfirmTotp"
+
+	res, err := s.db.ExecContext(ctx, `UPDATE user_totp SET confirmed_at = now(), last_used_step = $2
+				WHERE user_id = $1 AND confirmed_at IS NULL`, userID, step)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTotpConfirmed)
+	}
+
+	return nil
+}
+
+// UseTotpStep records accepted code, steps up to the last used one are rejected as replays
+func (s *Storage) UseTotpStep(ctx context.Context, userID int64, step int64) error {
+	const op = "storage.postgres.UseTotpStep"
+
+	res, err := s.db.ExecContext(ctx, `UPDATE user_totp SET last_used_step = $2
+				WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_used_step < $2`, userID, step)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTotpStepUsed)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveMfaChallenge(ctx context.Context, challenge models.MfaChallenge) error {
+	const op = "storage.postgres.SaveMfaChallenge"
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO mfa_challenge (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`,
+		challenge.UserID, challenge.TokenHash, challenge.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetMfaChallenge finds unused unexpired challenge without counting attempt
+func (s *Storage) GetMfaChallenge(ctx context.Context, tokenHash string, maxAttempts int) (models.MfaChallenge, error) {
+	const op = "storage.postgres.GetMfaChallenge"
+
+	query := `SELECT id, user_id, token_hash, expires_at, attempts FROM mfa_challenge
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now() AND attempts < $2`
+
+	var challenge models.MfaChallenge
+	err := s.db.GetContext(ctx, &challenge, query, tokenHash, maxAttempts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MfaChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrMfaChallengeNotFound)
+		}
+		return models.MfaChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return challenge, nil
+}
+
+// AttemptMfaChallenge counts attempt to answer unused unexpired challenge,
+// challenge with maxAttempts attempts already made is not found anymore
+func (s *Storage) AttemptMfaChallenge(ctx context.Context, tokenHash string, maxAttempts int) (models.MfaChallenge, error) {
+	const op = "storage.postgres.AttemptMfaChallenge"
+
+	query := `UPDATE mfa_challenge SET attempts = attempts + 1
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now() AND attempts < $2
+			RETURNING id, user_id, token_hash, expires_at, attempts`
+
+	var challenge models.MfaChallenge
+	err := s.db.GetContext(ctx, &challenge, query, tokenHash, maxAttempts)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MfaChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrMfaChallengeNotFound)
+		}
+		return models.MfaChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return challenge, nil
+}
+
+// ConsumeMfaChallenge marks answered challenge used, so it can not complete another login
+func (s *Storage) ConsumeMfaChallenge(ctx context.Context, challengeID int64) error {
+	const op = "storage.postgres.ConsumeMfaChallenge"
+
+	res, err := s.db.ExecContext(ctx, `UPDATE mfa_challenge SET used_at = now() WHERE id = $1 AND used_at IS NULL`, challengeID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMfaChallengeNotFound)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes drops all recovery codes of user, used or not, and saves new ones
+func (s *Storage) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	const op = "storage.postgres.ReplaceRecoveryCodes"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_code WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO mfa_recovery_code (user_id, code_hash) SELECT $1, unnest($2::TEXT[])`,
+		userID, pq.Array(codeHashes))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode consumes unused recovery code of user and returns how many unused codes are left
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (int, error) {
+	const op = "storage.postgres.UseRecoveryCode"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE mfa_recovery_code SET used_at = now()
+				WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrRecoveryCodeNotFound)
+	}
+
+	var remaining int
+	err = tx.GetContext(ctx, &remaining, `SELECT count(*) FROM mfa_recovery_code WHERE user_id = $1 AND used_at IS NULL`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return remaining, nil
+}
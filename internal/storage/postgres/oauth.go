@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+const oauthClientColumns = `id, name, secret_hash, redirect_uris, scopes, first_party, created_at`
+
+type oauthClientRow struct {
+	ID           string
+	Name         string
+	SecretHash   sql.NullString `db:"secret_hash"`
+	RedirectURIs pq.StringArray `db:"redirect_uris"`
+	Scopes       pq.StringArray
+	FirstParty   bool      `db:"first_party"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+func (r oauthClientRow) toModel() models.OAuthClient {
+	return models.OAuthClient{
+		ID:           r.ID,
+		Name:         r.Name,
+		SecretHash:   r.SecretHash.String,
+		RedirectURIs: r.RedirectURIs,
+		Scopes:       r.Scopes,
+		FirstParty:   r.FirstParty,
+		CreatedAt:    r.CreatedAt,
+	}
+}
+
+func (s *Storage) CreateOAuthClient(ctx context.Context, client models.OAuthClient) error {
+	const op = "storage.postgres.CreateOAuthClient"
+
+	query := `INSERT INTO oauth_client (id, name, secret_hash, redirect_uris, scopes, first_party)
+				VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		client.ID,
+		client.Name,
+		sql.NullString{String: client.SecretHash, Valid: client.SecretHash != ""},
+		pq.Array(nonNilStrings(client.RedirectURIs)),
+		pq.Array(nonNilStrings(client.Scopes)),
+		client.FirstParty,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrOAuthClientExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetOAuthClient(ctx context.Context, clientID string) (models.OAuthClient, error) {
+	const op = "storage.postgres.GetOAuthClient"
+
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_client WHERE id = $1`
+
+	var row oauthClientRow
+	err := s.db.GetContext(ctx, &row, query, clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OAuthClient{}, fmt.Errorf("%s: %w", op, storage.ErrOAuthClientNotFound)
+		}
+		return models.OAuthClient{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return row.toModel(), nil
+}
+
+func (s *Storage) ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error) {
+	const op = "storage.postgres.ListOAuthClients"
+
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_client ORDER BY created_at`
+
+	var rows []oauthClientRow
+	if err := s.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	clients := make([]models.OAuthClient, len(rows))
+	for i, row := range rows {
+		clients[i] = row.toModel()
+	}
+
+	return clients, nil
+}
+
+// DeleteOAuthClient removes client together with its codes and consents
+func (s *Storage) DeleteOAuthClient(ctx context.Context, clientID string) error {
+	const op = "storage.postgres.DeleteOAuthClient"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM oauth_client WHERE id = $1`, clientID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrOAuthClientNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveOAuthCode(ctx context.Context, code models.OAuthCode) error {
+	const op = "storage.postgres.SaveOAuthCode"
+
+	query := `INSERT INTO oauth_code
+					(code_hash, client_id, user_id, redirect_uri, scope, code_challenge, expires_at, nonce, auth_time)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope, code.CodeChallenge, code.ExpiresAt,
+		code.Nonce, code.AuthTime,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseOAuthCode marks unexpired code used and starts session of its user linked to the code in one statement,
+// so a concurrent replay always finds the session to revoke. Every code can be used once,
+// for already used code ErrOAuthCodeUsed is returned along with the code and its session
+func (s *Storage) UseOAuthCode(ctx context.Context, codeHash string, client models.ClientInfo) (models.OAuthCode, error) {
+	const op = "storage.postgres.UseOAuthCode"
+
+	const columns = `c.id, c.code_hash, c.client_id, c.user_id, c.redirect_uri, c.scope, c.code_challenge, c.session_id,
+				c.expires_at, c.nonce, c.auth_time`
+
+	// replay waits for the row lock and then skips the code, as it is used already
+	query := `WITH code AS (
+					SELECT id, user_id FROM oauth_code
+					WHERE code_hash = $1 AND used_at IS NULL AND expires_at > now()
+					FOR UPDATE
+				), new_session AS (
+					INSERT INTO session (user_id, user_agent, ip_address)
+					SELECT user_id, $2, $3 FROM code
+					RETURNING id
+				)
+				UPDATE oauth_code c SET used_at = now(), session_id = new_session.id
+				FROM code, new_session
+				WHERE c.id = code.id
+				RETURNING ` + columns
+
+	var code models.OAuthCode
+	err := s.db.GetContext(ctx, &code, query, codeHash, client.UserAgent, client.IPAddress)
+	if err == nil {
+		return code, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.OAuthCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.db.GetContext(ctx, &code, `SELECT `+columns+` FROM oauth_code c
+				WHERE c.code_hash = $1 AND c.used_at IS NOT NULL`, codeHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OAuthCode{}, fmt.Errorf("%s: %w", op, storage.ErrOAuthCodeNotFound)
+		}
+		return models.OAuthCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return code, fmt.Errorf("%s: %w", op, storage.ErrOAuthCodeUsed)
+}
+
+// GetOAuthConsent returns scopes user granted to client
+func (s *Storage) GetOAuthConsent(ctx context.Context, userID int64, clientID string) ([]string, error) {
+	const op = "storage.postgres.GetOAuthConsent"
+
+	var scopes pq.StringArray
+	err := s.db.GetContext(ctx, &scopes, `SELECT scopes FROM oauth_consent WHERE user_id = $1 AND client_id = $2`,
+		userID, clientID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, storage.ErrOAuthConsentNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return scopes, nil
+}
+
+// SaveOAuthConsent adds scopes to ones user already granted to client
+func (s *Storage) SaveOAuthConsent(ctx context.Context, userID int64, clientID string, scopes []string) error {
+	const op = "storage.postgres.SaveOAuthConsent"
+
+	query := `INSERT INTO oauth_consent (user_id, client_id, scopes) VALUES ($1, $2, $3)
+				ON CONFLICT (user_id, client_id) DO UPDATE
+				SET scopes = ARRAY(SELECT DISTINCT s FROM unnest(oauth_consent.scopes || EXCLUDED.scopes) AS s ORDER BY s),
+					updated_at = now()`
+
+	_, err := s.db.ExecContext(ctx, query, userID, clientID, pq.Array(nonNilStrings(scopes)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
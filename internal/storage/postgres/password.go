@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SavePasswordReset stores reset token, earlier unused tokens of the user stop working
+func (s *Storage) SavePasswordReset(ctx context.Context, reset models.PasswordReset) error {
+	const op = "storage.postgres.SavePasswordReset"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE password_reset SET used_at = now()
+				WHERE user_id = $1 AND used_at IS NULL`, reset.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO password_reset (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`,
+		reset.UserID, reset.TokenHash, reset.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetUserByPasswordReset returns owner of unused unexpired reset token without consuming it
+func (s *Storage) GetUserByPasswordReset(ctx context.Context, tokenHash string) (models.User, error) {
+	const op = "storage.postgres.GetUserByPasswordReset"
+
+	query := `SELECT ` + userColumns + ` FROM users
+			WHERE id = (SELECT user_id FROM password_reset
+				WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now())`
+
+	var user userRow
+	err := s.db.GetContext(ctx, &user, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrPasswordResetNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user.toModel(), nil
+}
+
+// ResetPassword consumes unexpired reset token and replaces password of its user.
+// Following the emailed link proves the email, so it becomes verified too
+func (s *Storage) ResetPassword(ctx context.Context, tokenHash string, passHash []byte) (int64, error) {
+	const op = "storage.postgres.ResetPassword"
+
+	query := `WITH r AS (
+				UPDATE password_reset SET used_at = now()
+				WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+				RETURNING user_id
+			)
+			UPDATE users u SET password = $2, email_verified = TRUE
+			FROM r
+			WHERE u.id = r.user_id
+			RETURNING u.id`
+
+	var userID int64
+	err := s.db.QueryRowContext(ctx, query, tokenHash, passHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrPasswordResetNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, userID int64, passHash []byte) error {
+	const op = "storage.postgres.UpdatePassword"
+
+	res, err := s.db.ExecContext(ctx, `UPDATE users SET password = $2 WHERE id = $1`, userID, passHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// ReplacePasswordHash swaps hash of the same password, it is a no-op if password was changed meanwhile
+func (s *Storage) ReplacePasswordHash(ctx context.Context, userID int64, oldHash []byte, newHash []byte) error {
+	const op = "storage.postgres.ReplacePasswordHash"
+
+	_, err := s.db.ExecContext(ctx, `UPDATE users SET password = $3 WHERE id = $1 AND password = $2`,
+		userID, oldHash, newHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -18,6 +18,30 @@ type Storage struct {
 	db *sqlx.DB
 }
 
+// userColumns selects user together with ids of all roles assigned to them
+const userColumns = `id, username, email, password, email_verified,
+	ARRAY(SELECT role_id FROM user_roles ur WHERE ur.user_id = users.id ORDER BY role_id) AS roles`
+
+type userRow struct {
+	ID            int64
+	Username      string
+	Email         string
+	Password      []byte
+	EmailVerified bool `db:"email_verified"`
+	Roles         pq.Int64Array
+}
+
+func (r userRow) toModel() models.User {
+	return models.User{
+		ID:            r.ID,
+		Username:      r.Username,
+		Email:         r.Email,
+		Password:      r.Password,
+		Roles:         r.Roles,
+		EmailVerified: r.EmailVerified,
+	}
+}
+
 func NewStorage(connString string) (*Storage, error) {
 	const op = "storage.postgres.New"
 
@@ -32,14 +56,20 @@ func NewStorage(connString string) (*Storage, error) {
 func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, string, error) {
 	const op = "storage.postgres.SaveUser"
 
-	query := `INSERT INTO users (username, email, password) VALUES ($1, $2, $3) RETURNING id`
+	// new users get default customer role
+	query := `WITH u AS (
+				INSERT INTO users (username, email, password) VALUES ($1, $2, $3) RETURNING id
+			), r AS (
+				INSERT INTO user_roles (user_id, role_id) SELECT id, $4 FROM u
+			)
+			SELECT id FROM u`
 
 	username, err := s.generateUniqueUsername()
 	if err != nil {
 		return 0, "", fmt.Errorf("internal error, try later")
 	}
 	var id int64
-	err = s.db.QueryRowContext(ctx, query, username, email, passHash).Scan(&id)
+	err = s.db.QueryRowContext(ctx, query, username, email, passHash, models.RoleCustomer).Scan(&id)
 	if err != nil {
 		var pqErr *pq.Error
 		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
@@ -54,9 +84,9 @@ func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (
 func (s *Storage) GetUser(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.postgres.GetUser"
 
-	query := `SELECT id, username, email, password, role_id FROM users WHERE email = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
 
-	var user models.User
+	var user userRow
 	err := s.db.GetContext(ctx, &user, query, email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -65,15 +95,15 @@ func (s *Storage) GetUser(ctx context.Context, email string) (models.User, error
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return user, nil
+	return user.toModel(), nil
 }
 
 func (s *Storage) GetUserByID(ctx context.Context, userID int64) (models.User, error) {
 	const op = "storage.postgres.GetUserByID"
 
-	query := `SELECT id, username, email, password, role_id FROM users WHERE id = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
-	var user models.User
+	var user userRow
 	err := s.db.GetContext(ctx, &user, query, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -82,7 +112,24 @@ func (s *Storage) GetUserByID(ctx context.Context, userID int64) (models.User, e
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return user, nil
+	return user.toModel(), nil
+}
+
+func (s *Storage) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
+	const op = "storage.postgres.GetUserByUsername"
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
+
+	var user userRow
+	err := s.db.GetContext(ctx, &user, query, username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user.toModel(), nil
 }
 
 func (s *Storage) GetUsers(ctx context.Context, roleID *int64, nameStartsWith *string) ([]models.User, error) {
@@ -92,10 +139,14 @@ func (s *Storage) GetUsers(ctx context.Context, roleID *int64, nameStartsWith *s
 	var conditions []string
 	argIndex := 1
 
-	query := `SELECT id, username, email, role_id FROM users WHERE 1=1`
+	query := `SELECT id, username, email, email_verified,
+				ARRAY(SELECT role_id FROM user_roles ur WHERE ur.user_id = users.id ORDER BY role_id) AS roles
+			FROM users WHERE 1=1`
 
 	if roleID != nil {
-		conditions = append(conditions, fmt.Sprintf(" AND role_id = $%d", argIndex))
+		conditions = append(conditions, fmt.Sprintf(
+			" AND EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = users.id AND ur.role_id = $%d)", argIndex,
+		))
 		args = append(args, *roleID)
 		argIndex++
 	}
@@ -108,32 +159,52 @@ func (s *Storage) GetUsers(ctx context.Context, roleID *int64, nameStartsWith *s
 
 	fullQuery := query + strings.Join(conditions, "")
 
-	var users []models.User
-	err := s.db.SelectContext(ctx, &users, fullQuery, args...)
+	var rows []userRow
+	err := s.db.SelectContext(ctx, &rows, fullQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	users := make([]models.User, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, row.toModel())
+	}
+
 	return users, nil
 }
 
+// DeleteUserByUsername removes user together with their roles, the last admin can not be deleted
 func (s *Storage) DeleteUserByUsername(ctx context.Context, username string) error {
 	const op = "storage.postgres.DeleteUserByUsername"
 
-	query := `DELETE FROM users WHERE username = $1`
-
-	res, err := s.db.ExecContext(ctx, query, username)
+	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	defer tx.Rollback()
 
-	affected, err := res.RowsAffected()
+	err = keepAdmin(ctx, tx, func() error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE username = $1`, username)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return storage.ErrUserNotFound
+		}
+
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if affected == 0 {
-		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
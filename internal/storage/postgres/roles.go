@@ -0,0 +1,359 @@
+package postgres
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// chainQuery selects roles $1 and all roles they inherit from. UNION stops on accidental cycles
+const chainQuery = `WITH RECURSIVE chain AS (
+					SELECT id, parent_id FROM role WHERE id = ANY($1)
+					UNION
+					SELECT r.id, r.parent_id FROM role r JOIN chain c ON r.id = c.parent_id
+				)`
+
+// GetRoleChain returns ids of roles together with ids of roles they inherit from
+func (s *Storage) GetRoleChain(ctx context.Context, roleIDs []int64) ([]int64, error) {
+	const op = "storage.postgres.GetRoleChain"
+
+	query := chainQuery + ` SELECT id FROM chain`
+
+	var ids []int64
+	err := s.db.SelectContext(ctx, &ids, query, pq.Array(roleIDs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// GetRolePermissions returns names of permissions granted to roles directly or through inheritance
+func (s *Storage) GetRolePermissions(ctx context.Context, roleIDs []int64) ([]string, error) {
+	const op = "storage.postgres.GetRolePermissions"
+
+	query := chainQuery + ` SELECT DISTINCT p.name
+				FROM chain
+				JOIN role_permission rp ON rp.role_id = chain.id
+				JOIN permission p ON p.id = rp.permission_id`
+
+	var permissions []string
+	err := s.db.SelectContext(ctx, &permissions, query, pq.Array(roleIDs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return permissions, nil
+}
+
+type roleRow struct {
+	ID          int64
+	Name        string
+	ParentID    *int64         `db:"parent_id"`
+	Permissions pq.StringArray `db:"permissions"`
+}
+
+func (r roleRow) toModel() models.Role {
+	return models.Role{
+		ID:          r.ID,
+		Name:        r.Name,
+		ParentID:    r.ParentID,
+		Permissions: r.Permissions,
+	}
+}
+
+const rolesQuery = `SELECT r.id, r.name, r.parent_id,
+					COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}') AS permissions
+				FROM role r
+				LEFT JOIN role_permission rp ON rp.role_id = r.id
+				LEFT JOIN permission p ON p.id = rp.permission_id`
+
+func (s *Storage) GetRoles(ctx context.Context) ([]models.Role, error) {
+	const op = "storage.postgres.GetRoles"
+
+	var rows []roleRow
+	err := s.db.SelectContext(ctx, &rows, rolesQuery+` GROUP BY r.id ORDER BY r.id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles := make([]models.Role, 0, len(rows))
+	for _, row := range rows {
+		roles = append(roles, row.toModel())
+	}
+
+	return roles, nil
+}
+
+func (s *Storage) SaveRole(ctx context.Context, role models.Role) (int64, error) {
+	const op = "storage.postgres.SaveRole"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `INSERT INTO role (name, parent_id) VALUES ($1, $2) RETURNING id`,
+		role.Name, role.ParentID,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, roleError(err))
+	}
+
+	if err := setRolePermissions(ctx, tx, id, role.Permissions); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// UpdateRole replaces name, parent and direct permissions of role,
+// it fails with ErrLastAdmin if the change takes admin rights away from the last admin
+func (s *Storage) UpdateRole(ctx context.Context, role models.Role) error {
+	const op = "storage.postgres.UpdateRole"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	err = keepAdmin(ctx, tx, func() error {
+		res, err := tx.ExecContext(ctx, `UPDATE role SET name = $2, parent_id = $3 WHERE id = $1`,
+			role.ID, role.Name, role.ParentID,
+		)
+		if err != nil {
+			return roleError(err)
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return storage.ErrRoleNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM role_permission WHERE role_id = $1`, role.ID); err != nil {
+			return err
+		}
+
+		return setRolePermissions(ctx, tx, role.ID, role.Permissions)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteRole removes role, it fails with ErrRoleInUse while any user has it and with ErrLastAdmin
+// if roles inheriting from it lose admin rights of the last admin
+func (s *Storage) DeleteRole(ctx context.Context, roleID int64) error {
+	const op = "storage.postgres.DeleteRole"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	err = keepAdmin(ctx, tx, func() error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM role WHERE id = $1`, roleID)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+				return storage.ErrRoleInUse
+			}
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return storage.ErrRoleNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddUserRole assigns role to user in addition to roles they already have
+func (s *Storage) AddUserRole(ctx context.Context, userID int64, roleID int64) error {
+	const op = "storage.postgres.AddUserRole"
+
+	query := `INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	_, err := s.db.ExecContext(ctx, query, userID, roleID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveUserRole takes role away from user, refusing to take admin role away from the last admin
+func (s *Storage) RemoveUserRole(ctx context.Context, userID int64, roleID int64) error {
+	const op = "storage.postgres.RemoveUserRole"
+
+	err := s.removeUserRole(ctx, userID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) removeUserRole(ctx context.Context, userID int64, roleID int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = keepAdmin(ctx, tx, func() error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2`, userID, roleID)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return storage.ErrRoleNotAssigned
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// keepAdmin applies change within tx and fails with ErrLastAdmin if there was an admin before it and there is none after.
+// Admin users are locked before the change, so concurrent guarded changes are serialized and the guard is race free
+func keepAdmin(ctx context.Context, tx *sqlx.Tx, change func() error) error {
+	before, err := hasAdmin(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	if !before {
+		return nil
+	}
+	after, err := hasAdmin(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if !after {
+		return storage.ErrLastAdmin
+	}
+
+	return nil
+}
+
+// hasAdmin locks users holding admin role directly or through inheritance and reports whether
+// any of them is left while admin role grants roles:manage, otherwise nobody can restore admin rights
+func hasAdmin(ctx context.Context, tx *sqlx.Tx) (bool, error) {
+	adminUsersQuery := `WITH RECURSIVE admins AS (
+					SELECT id FROM role WHERE id = $1
+					UNION
+					SELECT r.id FROM role r JOIN admins a ON r.parent_id = a.id
+				)
+				SELECT id FROM users
+				WHERE id IN (SELECT user_id FROM user_roles WHERE role_id IN (SELECT id FROM admins))
+				FOR UPDATE`
+
+	var adminUsers []int64
+	if err := tx.SelectContext(ctx, &adminUsers, adminUsersQuery, models.RoleAdmin); err != nil {
+		return false, err
+	}
+	if len(adminUsers) == 0 {
+		return false, nil
+	}
+
+	managesRolesQuery := chainQuery + ` SELECT EXISTS(
+					SELECT 1 FROM chain
+					JOIN role_permission rp ON rp.role_id = chain.id
+					JOIN permission p ON p.id = rp.permission_id
+					WHERE p.name = $2
+				)`
+
+	var managesRoles bool
+	err := tx.QueryRowContext(ctx, managesRolesQuery, pq.Array([]int64{models.RoleAdmin}), models.PermissionRolesManage).
+		Scan(&managesRoles)
+	if err != nil {
+		return false, err
+	}
+
+	return managesRoles, nil
+}
+
+func setRolePermissions(ctx context.Context, tx *sqlx.Tx, roleID int64, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO permission (name)
+				SELECT unnest($1::text[])
+				ON CONFLICT (name) DO NOTHING`, pq.Array(permissions))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO role_permission (role_id, permission_id)
+				SELECT $1, id FROM permission WHERE name = ANY($2)
+				ON CONFLICT DO NOTHING`, roleID, pq.Array(permissions))
+
+	return err
+}
+
+// roleError maps constraint violations on role table to storage errors
+func roleError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return storage.ErrRoleExists
+		case "23503":
+			return storage.ErrRoleNotFound // unknown parent
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,247 @@
+package postgres
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+func (s *Storage) CreateSession(ctx context.Context, userID int64, client models.ClientInfo) (string, error) {
+	const op = "storage.postgres.CreateSession"
+
+	query := `INSERT INTO session (user_id, user_agent, ip_address) VALUES ($1, $2, $3) RETURNING id`
+
+	var id string
+	err := s.db.QueryRowContext(ctx, query, userID, client.UserAgent, client.IPAddress).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) SaveRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	const op = "storage.postgres.SaveRefreshToken"
+
+	query := `INSERT INTO refresh_token (session_id, token_hash, expires_at, access_jti, access_expires_at)
+				VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		token.SessionID, token.TokenHash, token.ExpiresAt, token.AccessTokenID, token.AccessTokenExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseRefreshToken marks refresh token as used. Every token can be used once,
+// for already used token ErrRefreshTokenUsed is returned along with its session
+func (s *Storage) UseRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	const op = "storage.postgres.UseRefreshToken"
+
+	query := `UPDATE refresh_token rt SET used_at = now()
+				FROM session s
+				WHERE rt.token_hash = $1 AND rt.used_at IS NULL AND s.id = rt.session_id
+				RETURNING rt.id, rt.session_id, s.user_id, rt.expires_at, s.revoked_at IS NOT NULL AS session_revoked`
+
+	var token models.RefreshToken
+	err := s.db.GetContext(ctx, &token, query, tokenHash)
+	if err == nil {
+		return token, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err = s.GetRefreshToken(ctx, tokenHash)
+	if err != nil {
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, fmt.Errorf("%s: %w", op, storage.ErrRefreshTokenUsed)
+}
+
+func (s *Storage) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	const op = "storage.postgres.GetRefreshToken"
+
+	query := `SELECT rt.id, rt.session_id, s.user_id, rt.expires_at,
+					rt.used_at IS NOT NULL AS used, s.revoked_at IS NOT NULL AS session_revoked
+				FROM refresh_token rt
+				JOIN session s ON s.id = rt.session_id
+				WHERE rt.token_hash = $1`
+
+	var token models.RefreshToken
+	err := s.db.GetContext(ctx, &token, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RefreshToken{}, fmt.Errorf("%s: %w", op, storage.ErrRefreshTokenNotFound)
+		}
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return token, nil
+}
+
+func (s *Storage) TouchSession(ctx context.Context, sessionID string) error {
+	const op = "storage.postgres.TouchSession"
+
+	_, err := s.db.ExecContext(ctx, `UPDATE session SET last_refreshed_at = now() WHERE id = $1`, sessionID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetUserSessions returns sessions which still have usable refresh token
+func (s *Storage) GetUserSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	const op = "storage.postgres.GetUserSessions"
+
+	query := `SELECT s.id, s.user_id, s.user_agent, s.ip_address, s.created_at, s.last_refreshed_at, s.revoked_at
+				FROM session s
+				WHERE s.user_id = $1 AND s.revoked_at IS NULL AND EXISTS (
+					SELECT 1 FROM refresh_token rt
+					WHERE rt.session_id = s.id AND rt.used_at IS NULL AND rt.expires_at > now()
+				)
+				ORDER BY COALESCE(s.last_refreshed_at, s.created_at) DESC`
+
+	var sessions []models.Session
+	err := s.db.SelectContext(ctx, &sessions, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+func (s *Storage) GetSession(ctx context.Context, sessionID string) (models.Session, error) {
+	const op = "storage.postgres.GetSession"
+
+	query := `SELECT id, user_id, user_agent, ip_address, created_at, last_refreshed_at, revoked_at
+				FROM session WHERE id = $1`
+
+	var session models.Session
+	err := s.db.GetContext(ctx, &session, query, sessionID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+// RevokeSession revokes token family and denylists access tokens issued within it
+func (s *Storage) RevokeSession(ctx context.Context, sessionID string) error {
+	const op = "storage.postgres.RevokeSession"
+
+	_, err := s.revokeSessions(ctx, `SELECT id FROM session WHERE id = $1 AND revoked_at IS NULL`, sessionID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeUserSession revokes session only if it belongs to the user
+func (s *Storage) RevokeUserSession(ctx context.Context, userID int64, sessionID string) error {
+	const op = "storage.postgres.RevokeUserSession"
+
+	revoked, err := s.revokeSessions(ctx,
+		`SELECT id FROM session WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		sessionID, userID,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "22P02" {
+			// malformed uuid
+			return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if revoked == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+// RevokeUserSessions revokes every session of the user
+func (s *Storage) RevokeUserSessions(ctx context.Context, userID int64) error {
+	const op = "storage.postgres.RevokeUserSessions"
+
+	_, err := s.revokeSessions(ctx, `SELECT id FROM session WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions revokes every session of the user except keepSessionID
+func (s *Storage) RevokeOtherSessions(ctx context.Context, userID int64, keepSessionID string) error {
+	const op = "storage.postgres.RevokeOtherSessions"
+
+	_, err := s.revokeSessions(ctx, `SELECT id FROM session
+				WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL`, userID, keepSessionID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	const op = "storage.postgres.IsTokenRevoked"
+
+	var revoked bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM revoked_token WHERE jti = $1)`, jti).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// revokeSessions revokes sessions selected by sessionsQuery in one transaction
+// and returns how many of them were revoked
+func (s *Storage) revokeSessions(ctx context.Context, sessionsQuery string, args ...any) (int64, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var revoked int64
+	err = tx.QueryRowContext(ctx, `WITH revoked AS (
+					UPDATE session SET revoked_at = now()
+					WHERE id IN (`+sessionsQuery+`)
+					RETURNING id
+				), denylisted AS (
+					INSERT INTO revoked_token (jti, expires_at)
+					SELECT rt.access_jti, rt.access_expires_at
+					FROM refresh_token rt
+					JOIN revoked ON revoked.id = rt.session_id
+					WHERE rt.access_jti IS NOT NULL AND rt.access_expires_at > now()
+					ON CONFLICT (jti) DO NOTHING
+				)
+				SELECT count(*) FROM revoked`, args...).Scan(&revoked)
+	if err != nil {
+		return 0, err
+	}
+
+	// denylist entries are useless once tokens are expired anyway
+	_, err = tx.ExecContext(ctx, `DELETE FROM revoked_token WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, err
+	}
+
+	return revoked, tx.Commit()
+}
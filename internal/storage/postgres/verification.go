@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SaveEmailVerification stores verification, it fails with ErrVerificationTooSoon
+// if the previous one of the user was created less than resendInterval ago
+func (s *Storage) SaveEmailVerification(
+	ctx context.Context,
+	verification models.EmailVerification,
+	resendInterval time.Duration,
+) error {
+	const op = "storage.postgres.SaveEmailVerification"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// lock user row, so concurrent requests can not both pass the interval check
+	_, err = tx.ExecContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, verification.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var recent bool
+	err = tx.GetContext(ctx, &recent, `SELECT EXISTS (SELECT 1 FROM email_verification
+				WHERE user_id = $1 AND created_at > now() - make_interval(secs => $2))`,
+		verification.UserID, resendInterval.Seconds())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if recent {
+		return fmt.Errorf("%s: %w", op, storage.ErrVerificationTooSoon)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO email_verification (user_id, email, token_hash, expires_at) VALUES ($1, $2, $3, $4)`,
+		verification.UserID,
+		verification.Email,
+		verification.TokenHash,
+		verification.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// VerifyEmail consumes unexpired verification and marks email of its user verified.
+// Verification of email the user no longer has is consumed but verifies nothing
+func (s *Storage) VerifyEmail(ctx context.Context, tokenHash string) (int64, error) {
+	const op = "storage.postgres.VerifyEmail"
+
+	query := `WITH v AS (
+				UPDATE email_verification SET used_at = now()
+				WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+				RETURNING user_id, email
+			)
+			UPDATE users u SET email_verified = TRUE
+			FROM v
+			WHERE u.id = v.user_id AND u.email = v.email
+			RETURNING u.id`
+
+	var userID int64
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrVerificationNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"auth-service/internal/domain/models"
+	"auth-service/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+const webauthnCredentialColumns = `id, user_id, credential_id, public_key, sign_count, aaguid, transports,
+			name, backup_eligible, backed_up, created_at, last_used_at`
+
+type webauthnCredentialRow struct {
+	ID             int64
+	UserID         int64  `db:"user_id"`
+	CredentialID   []byte `db:"credential_id"`
+	PublicKey      []byte `db:"public_key"`
+	SignCount      int64  `db:"sign_count"`
+	AAGUID         []byte
+	Transports     pq.StringArray
+	Name           string
+	BackupEligible bool       `db:"backup_eligible"`
+	BackedUp       bool       `db:"backed_up"`
+	CreatedAt      time.Time  `db:"created_at"`
+	LastUsedAt     *time.Time `db:"last_used_at"`
+}
+
+func (r webauthnCredentialRow) toModel() models.WebauthnCredential {
+	return models.WebauthnCredential{
+		ID:             r.ID,
+		UserID:         r.UserID,
+		CredentialID:   r.CredentialID,
+		PublicKey:      r.PublicKey,
+		SignCount:      r.SignCount,
+		AAGUID:         r.AAGUID,
+		Transports:     r.Transports,
+		Name:           r.Name,
+		BackupEligible: r.BackupEligible,
+		BackedUp:       r.BackedUp,
+		CreatedAt:      r.CreatedAt,
+		LastUsedAt:     r.LastUsedAt,
+	}
+}
+
+func (s *Storage) SaveWebauthnCredential(ctx context.Context, credential models.WebauthnCredential) (int64, error) {
+	const op = "storage.postgres.SaveWebauthnCredential"
+
+	query := `INSERT INTO webauthn_credential
+				(user_id, credential_id, public_key, sign_count, aaguid, transports, name, backup_eligible, backed_up)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id`
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, query,
+		credential.UserID,
+		credential.CredentialID,
+		credential.PublicKey,
+		credential.SignCount,
+		credential.AAGUID,
+		pq.Array(nonNilStrings(credential.Transports)),
+		credential.Name,
+		credential.BackupEligible,
+		credential.BackedUp,
+	).Scan(&id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrWebauthnCredentialExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetWebauthnCredentials(ctx context.Context, userID int64) ([]models.WebauthnCredential, error) {
+	const op = "storage.postgres.GetWebauthnCredentials"
+
+	query := `SELECT ` + webauthnCredentialColumns + ` FROM webauthn_credential WHERE user_id = $1 ORDER BY id`
+
+	var rows []webauthnCredentialRow
+	if err := s.db.SelectContext(ctx, &rows, query, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	credentials := make([]models.WebauthnCredential, len(rows))
+	for i, row := range rows {
+		credentials[i] = row.toModel()
+	}
+
+	return credentials, nil
+}
+
+// GetWebauthnCredential finds credential by ID authenticator assigned to it
+func (s *Storage) GetWebauthnCredential(ctx context.Context, credentialID []byte) (models.WebauthnCredential, error) {
+	const op = "storage.postgres.GetWebauthnCredential"
+
+	query := `SELECT ` + webauthnCredentialColumns + ` FROM webauthn_credential WHERE credential_id = $1`
+
+	var row webauthnCredentialRow
+	err := s.db.GetContext(ctx, &row, query, credentialID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebauthnCredential{}, fmt.Errorf("%s: %w", op, storage.ErrWebauthnCredentialNotFound)
+		}
+		return models.WebauthnCredential{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return row.toModel(), nil
+}
+
+// UseWebauthnCredential stores signature counter of successful assertion. Counter must grow,
+// unless authenticator does not support it and always reports 0, otherwise credential may be cloned
+func (s *Storage) UseWebauthnCredential(ctx context.Context, id int64, signCount int64, backedUp bool) error {
+	const op = "storage.postgres.UseWebauthnCredential"
+
+	res, err := s.db.ExecContext(ctx, `UPDATE webauthn_credential
+				SET sign_count = $2, backed_up = $3, last_used_at = now()
+				WHERE id = $1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0))`, id, signCount, backedUp)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrWebauthnSignCount)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveWebauthnChallenge(ctx context.Context, challenge models.WebauthnChallenge) error {
+	const op = "storage.postgres.SaveWebauthnChallenge"
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO webauthn_challenge (user_id, challenge_hash, ceremony, expires_at)
+				VALUES ($1, $2, $3, $4)`,
+		challenge.UserID, challenge.ChallengeHash, challenge.Ceremony, challenge.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ConsumeWebauthnChallenge marks unused unexpired challenge of ceremony used, so response can not be replayed
+func (s *Storage) ConsumeWebauthnChallenge(ctx context.Context, challengeHash string, ceremony string) (models.WebauthnChallenge, error) {
+	const op = "storage.postgres.ConsumeWebauthnChallenge"
+
+	query := `UPDATE webauthn_challenge SET used_at = now()
+			WHERE challenge_hash = $1 AND ceremony = $2 AND used_at IS NULL AND expires_at > now()
+			RETURNING id, user_id, challenge_hash, ceremony, expires_at`
+
+	var challenge models.WebauthnChallenge
+	err := s.db.GetContext(ctx, &challenge, query, challengeHash, ceremony)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebauthnChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrWebauthnChallengeNotFound)
+		}
+		return models.WebauthnChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return challenge, nil
+}
+
+// nonNilStrings makes empty list stored as '{}' instead of NULL
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -5,4 +5,41 @@ import "errors"
 var (
 	ErrUserExists   = errors.New("user already exists")
 	ErrUserNotFound = errors.New("user not found")
+	ErrKeyExists    = errors.New("signing key already exists")
+	ErrKeyNotFound  = errors.New("signing key not found")
+
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenUsed     = errors.New("refresh token already used")
+	ErrSessionNotFound      = errors.New("session not found")
+
+	ErrRoleExists      = errors.New("role already exists")
+	ErrRoleNotFound    = errors.New("role not found")
+	ErrRoleInUse       = errors.New("role is assigned to users")
+	ErrRoleNotAssigned = errors.New("role is not assigned to user")
+	ErrLastAdmin       = errors.New("last admin can not lose admin role")
+
+	ErrVerificationNotFound  = errors.New("email verification not found")
+	ErrVerificationTooSoon   = errors.New("email verification was sent recently")
+	ErrPasswordResetNotFound = errors.New("password reset not found")
+	ErrLoginCodeNotFound     = errors.New("login code not found")
+	ErrLoginCodeTooSoon      = errors.New("login code was sent recently")
+
+	ErrTotpNotFound         = errors.New("totp enrollment not found")
+	ErrTotpConfirmed        = errors.New("totp is already confirmed")
+	ErrTotpStepUsed         = errors.New("totp code already used")
+	ErrMfaChallengeNotFound = errors.New("mfa challenge not found")
+	ErrRecoveryCodeNotFound = errors.New("recovery code not found")
+
+	ErrWebauthnCredentialExists   = errors.New("webauthn credential already exists")
+	ErrWebauthnCredentialNotFound = errors.New("webauthn credential not found")
+	ErrWebauthnSignCount          = errors.New("webauthn signature counter did not increase")
+	ErrWebauthnChallengeNotFound  = errors.New("webauthn challenge not found")
+
+	ErrOAuthClientExists    = errors.New("oauth client already exists")
+	ErrOAuthClientNotFound  = errors.New("oauth client not found")
+	ErrOAuthCodeNotFound    = errors.New("oauth authorization code not found")
+	ErrOAuthCodeUsed        = errors.New("oauth authorization code already used")
+	ErrOAuthConsentNotFound = errors.New("oauth consent not found")
+
+	ErrTooManyAttempts = errors.New("too many failed attempts")
 )
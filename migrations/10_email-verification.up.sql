@@ -0,0 +1,18 @@
+ALTER TABLE users
+ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- accounts created before verification existed are trusted
+UPDATE users SET email_verified = TRUE;
+
+CREATE TABLE IF NOT EXISTS email_verification
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email      VARCHAR(255) NOT NULL,
+    token_hash TEXT         NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ  NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verification_user_id ON email_verification (user_id);
@@ -0,0 +1,24 @@
+-- secret is encrypted by the service, key is never stored in database
+CREATE TABLE IF NOT EXISTS user_totp
+(
+    user_id        INT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret         BYTEA       NOT NULL,
+    -- enrollment is pending until the first code is confirmed
+    confirmed_at   TIMESTAMPTZ,
+    -- time step of the last accepted code, older and the same steps are replays
+    last_used_step BIGINT      NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS mfa_challenge
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT        NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    attempts   INT         NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_challenge_user_id ON mfa_challenge (user_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS mfa_recovery_code
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at    TIMESTAMPTZ,
+    UNIQUE (user_id, code_hash)
+);
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS webauthn_credential
+(
+    id              SERIAL PRIMARY KEY,
+    user_id         INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    credential_id   BYTEA       NOT NULL UNIQUE,
+    -- COSE_Key of the credential
+    public_key      BYTEA       NOT NULL,
+    -- signature counter, authenticators not supporting it always report 0
+    sign_count      BIGINT      NOT NULL DEFAULT 0,
+    aaguid          BYTEA,
+    transports      TEXT[]      NOT NULL DEFAULT '{}',
+    name            TEXT        NOT NULL DEFAULT '',
+    backup_eligible BOOLEAN     NOT NULL DEFAULT FALSE,
+    backed_up       BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credential_user_id ON webauthn_credential (user_id);
+
+-- challenge is looked up by its hash from signed client data, so ceremony needs no extra token
+CREATE TABLE IF NOT EXISTS webauthn_challenge
+(
+    id             SERIAL PRIMARY KEY,
+    -- empty for passwordless login, user is known only from the credential
+    user_id        INT REFERENCES users (id) ON DELETE CASCADE,
+    challenge_hash TEXT        NOT NULL UNIQUE,
+    ceremony       TEXT        NOT NULL,
+    expires_at     TIMESTAMPTZ NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at        TIMESTAMPTZ
+);
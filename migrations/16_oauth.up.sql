@@ -0,0 +1,40 @@
+-- third-party applications allowed to request tokens, public clients (SPA, mobile) have no secret
+CREATE TABLE IF NOT EXISTS oauth_client
+(
+    id            TEXT PRIMARY KEY,
+    name          TEXT        NOT NULL,
+    secret_hash   TEXT,
+    redirect_uris TEXT[]      NOT NULL,
+    scopes        TEXT[]      NOT NULL DEFAULT '{}',
+    -- first party clients are trusted and do not ask user for consent
+    first_party   BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS oauth_code
+(
+    id             SERIAL PRIMARY KEY,
+    code_hash      TEXT        NOT NULL UNIQUE,
+    client_id      TEXT        NOT NULL REFERENCES oauth_client (id) ON DELETE CASCADE,
+    user_id        INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    redirect_uri   TEXT        NOT NULL,
+    scope          TEXT        NOT NULL,
+    code_challenge TEXT        NOT NULL,
+    -- session started by exchanging the code, it is revoked when the code is replayed
+    session_id     UUID REFERENCES session (id) ON DELETE SET NULL,
+    expires_at     TIMESTAMPTZ NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at        TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS oauth_consent
+(
+    user_id    INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_id  TEXT        NOT NULL REFERENCES oauth_client (id) ON DELETE CASCADE,
+    scopes     TEXT[]      NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, client_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_consent_client_id ON oauth_consent (client_id);
@@ -0,0 +1,4 @@
+-- OpenID Connect: nonce of authorization request and time user logged in are put into ID token
+ALTER TABLE oauth_code
+    ADD COLUMN IF NOT EXISTS nonce     TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS auth_time TIMESTAMPTZ;
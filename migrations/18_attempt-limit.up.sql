@@ -0,0 +1,10 @@
+-- failed attempts of user counted across mfa challenges and login codes, kind is mfa || login_code.
+-- Attempts are refused once failures reach the limit, until the window since window_start passes
+CREATE TABLE IF NOT EXISTS attempt_limit
+(
+    user_id      INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    kind         TEXT        NOT NULL,
+    failures     INT         NOT NULL DEFAULT 0,
+    window_start TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, kind)
+);
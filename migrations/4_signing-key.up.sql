@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS signing_key
+(
+    kid          TEXT PRIMARY KEY,
+    algorithm    TEXT        NOT NULL,
+    private_key  TEXT        NOT NULL,
+    status       TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    retired_at   TIMESTAMPTZ,
+    verify_until TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_key_active ON signing_key (status) WHERE status = 'active';
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS session
+(
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_user_id ON session (user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_token
+(
+    id         SERIAL PRIMARY KEY,
+    session_id UUID        NOT NULL REFERENCES session (id) ON DELETE CASCADE,
+    token_hash TEXT        NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_token_session_id ON refresh_token (session_id);
@@ -0,0 +1,11 @@
+ALTER TABLE refresh_token
+ADD COLUMN access_jti TEXT,
+ADD COLUMN access_expires_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS revoked_token
+(
+    jti        TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_token_expires_at ON revoked_token (expires_at);
@@ -0,0 +1,4 @@
+ALTER TABLE session
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '',
+ADD COLUMN last_refreshed_at TIMESTAMPTZ;
@@ -0,0 +1,16 @@
+ALTER TABLE role
+ADD COLUMN parent_id INT REFERENCES role (id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS permission
+(
+    id          SERIAL PRIMARY KEY,
+    name        TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permission
+(
+    role_id       INT NOT NULL REFERENCES role (id) ON DELETE CASCADE,
+    permission_id INT NOT NULL REFERENCES permission (id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS user_roles
+(
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role_id INT NOT NULL REFERENCES role (id),
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
+
+INSERT INTO user_roles (user_id, role_id)
+SELECT id, role_id FROM users WHERE role_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+DROP INDEX IF EXISTS idx_role_id;
+
+ALTER TABLE users
+DROP CONSTRAINT IF EXISTS fk_role,
+DROP COLUMN IF EXISTS role_id;
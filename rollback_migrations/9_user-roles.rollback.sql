@@ -0,0 +1,15 @@
+ALTER TABLE users
+ADD COLUMN role_id INT DEFAULT 2;
+
+-- single role column keeps the lowest role id, which is admin for admins
+UPDATE users u
+SET role_id = (SELECT min(ur.role_id) FROM user_roles ur WHERE ur.user_id = u.id)
+WHERE EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id);
+
+ALTER TABLE users
+ADD CONSTRAINT fk_role
+FOREIGN KEY (role_id) REFERENCES role (id);
+
+CREATE INDEX IF NOT EXISTS idx_role_id ON users (role_id);
+
+DROP TABLE IF EXISTS user_roles;